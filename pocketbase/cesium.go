@@ -0,0 +1,196 @@
+package main
+
+import (
+	"app/lib/elevation"
+	"app/lib/mesh"
+	"app/lib/palettes"
+	"app/lib/quantizedmesh"
+	"app/lib/tilemath"
+	utils "app/lib/utils"
+	"image/color"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// terrainSource is the stored elevation of a tile record together with the
+// area it covers.
+type terrainSource struct {
+	tile *models.Record
+	grid *elevation.Grid
+	bbox tilemath.BBox
+}
+
+func loadTerrainSource(app *pocketbase.PocketBase, id string) (*terrainSource, error) {
+	tile, err := app.Dao().FindRecordById("tiles", id)
+	if err != nil {
+		return nil, apis.NewNotFoundError("", err)
+	}
+
+	heightmap, err := app.Dao().FindRecordById("heightmaps", tile.GetString("heightmap"))
+	if err != nil {
+		return nil, apis.NewBadRequestError("The tile has no heightmap.", err)
+	}
+	grid, err := loadElevation(app, heightmap)
+	if err != nil {
+		return nil, apis.NewBadRequestError("The tile's heightmap has no elevation data.", err)
+	}
+
+	coords, err := utils.CoordsFromBboxString(tile.GetString("bbox"))
+	if err != nil {
+		return nil, apis.NewBadRequestError("The tile has no valid bbox.", err)
+	}
+
+	return &terrainSource{tile: tile, grid: grid, bbox: tilemath.BBox(coords)}, nil
+}
+
+// heightAt returns the elevation at a point, 0 outside the stored area.
+func (s *terrainSource) heightAt(lon float64, lat float64) float64 {
+	if lon < s.bbox.West() || lon > s.bbox.East() || lat < s.bbox.South() || lat > s.bbox.North() {
+		return 0
+	}
+	fx, fy := pixelForLonLat(s.bbox, s.grid.Width, s.grid.Height, lon, lat)
+	return s.grid.Sample(fx, fy)
+}
+
+// waterMask returns the quantized-mesh water mask of a Cesium tile from the
+// landcover water class, or nil when the tile has no landcover.
+func (s *terrainSource) waterMask(app *pocketbase.PocketBase, bounds tilemath.BBox, landcoverPalettes *palettes.Store) []byte {
+	landcover, err := app.Dao().FindRecordById("landcovers", s.tile.GetString("landcover"))
+	if err != nil || landcover.GetString("color") == "" {
+		return nil
+	}
+	palette := landcoverPalettes.ForRecord(landcover)
+
+	img, err := imaging.Open(utils.GetPathForFileField(landcover, landcover.Collection(), app.DataDir(), "color"))
+	if err != nil {
+		return nil
+	}
+
+	// landcover images only hold palette colors, so classify each once
+	water := map[color.NRGBA]bool{}
+	isWater := func(c color.Color) bool {
+		nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+		if _, ok := water[nrgba]; !ok {
+			water[nrgba] = utils.GetClosestColorName(nrgba, palette) == "water"
+		}
+		return water[nrgba]
+	}
+
+	const size = 256
+	imgBounds := img.Bounds()
+	mask := make([]byte, size*size)
+	waterCount := 0
+	tile := quantizedmesh.Tile{BBox: bounds}
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			lon, lat := tile.LonLat((float64(x)+0.5)/size, (float64(y)+0.5)/size)
+			if lon < s.bbox.West() || lon > s.bbox.East() || lat < s.bbox.South() || lat > s.bbox.North() {
+				continue
+			}
+			fx, fy := pixelForLonLat(s.bbox, imgBounds.Dx(), imgBounds.Dy(), lon, lat)
+			px := min(imgBounds.Dx()-1, int(fx)) + imgBounds.Min.X
+			py := min(imgBounds.Dy()-1, int(fy)) + imgBounds.Min.Y
+			if isWater(img.At(px, py)) {
+				mask[y*size+x] = 255
+				waterCount++
+			}
+		}
+	}
+
+	// uniform tiles are sent as a single byte
+	switch waterCount {
+	case 0:
+		return []byte{0}
+	case size * size:
+		return []byte{255}
+	}
+	return mask
+}
+
+// onCesiumLayer handles GET /api/terrain/tiles/:id/cesium/layer.json, the
+// entry point of a Cesium terrain provider for the tile or region.
+func onCesiumLayer(c echo.Context, app *pocketbase.PocketBase) error {
+	source, err := loadTerrainSource(app, c.PathParam("id"))
+	if err != nil {
+		return err
+	}
+
+	maxZoom := source.tile.GetInt("zoom")
+	if metersPerPixel := source.tile.GetFloat("metersPerPixel"); metersPerPixel > 0 {
+		_, lat := source.bbox.Center()
+		maxZoom = max(maxZoom, quantizedmesh.MaxZoomForMetersPerPixel(metersPerPixel, lat))
+	}
+
+	return c.JSON(http.StatusOK, quantizedmesh.NewLayer(source.tile.Id, source.bbox, maxZoom))
+}
+
+// onCesiumTile handles GET /api/terrain/tiles/:id/cesium/:z/:x/:y.terrain and
+// returns a quantized-mesh-1.0 tile in the TMS scheme. The octvertexnormals
+// and watermask extensions are included when the Accept header asks for
+// them, as Cesium does.
+func onCesiumTile(c echo.Context, app *pocketbase.PocketBase, landcoverPalettes *palettes.Store) error {
+	z, zErr := strconv.Atoi(c.PathParam("z"))
+	x, xErr := strconv.Atoi(c.PathParam("x"))
+	tmsY, yErr := strconv.Atoi(strings.TrimSuffix(c.PathParam("y"), ".terrain"))
+	if zErr != nil || xErr != nil || yErr != nil {
+		return apis.NewNotFoundError("", nil)
+	}
+	tile := tilemath.Tile{X: x, Y: (1<<z - 1) - tmsY, Z: z}
+	if !tile.Valid() {
+		return apis.NewNotFoundError("", nil)
+	}
+
+	source, err := loadTerrainSource(app, c.PathParam("id"))
+	if err != nil {
+		return err
+	}
+
+	normals, waterMask := false, false
+	for _, part := range strings.Split(c.Request().Header.Get(echo.HeaderAccept), ";") {
+		if extensions, ok := strings.CutPrefix(strings.TrimSpace(part), "extensions="); ok {
+			for _, extension := range strings.Split(extensions, "-") {
+				normals = normals || extension == "octvertexnormals"
+				waterMask = waterMask || extension == "watermask"
+			}
+		}
+	}
+
+	// the grid is resampled so its rows run linearly in latitude, as Cesium
+	// reads them, rather than in Web Mercator like the tile's own pixels
+	bounds := tile.Bounds()
+	encoded := quantizedmesh.Tile{BBox: bounds}
+	grid := elevation.NewGrid(quantizedmesh.GridSize, quantizedmesh.GridSize)
+	for row := 0; row < grid.Height; row++ {
+		for column := 0; column < grid.Width; column++ {
+			lon, lat := encoded.LonLat(float64(column)/float64(grid.Width-1), float64(row)/float64(grid.Height-1))
+			grid.Set(column, row, source.heightAt(lon, lat))
+		}
+	}
+
+	// normals are computed on the mesh in meters, so it needs the ground size
+	// of the tile
+	_, lat := tile.Center()
+	width := 2 * math.Pi * quantizedmesh.EllipsoidA * math.Cos(lat*math.Pi/180) / math.Exp2(float64(z))
+	length := (bounds.North() - bounds.South()) * math.Pi / 180 * quantizedmesh.EllipsoidA
+	rtin, err := mesh.NewRTIN(grid)
+	if err != nil {
+		return err
+	}
+	encoded.Mesh = rtin.Mesh(quantizedmesh.GeometricError(z), width, length)
+
+	if waterMask {
+		encoded.WaterMask = source.waterMask(app, bounds, landcoverPalettes)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "application/vnd.quantized-mesh")
+	c.Response().WriteHeader(http.StatusOK)
+	return encoded.Encode(c.Response(), normals, waterMask)
+}
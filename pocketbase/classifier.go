@@ -0,0 +1,136 @@
+package main
+
+import (
+	"app/lib/classify"
+	"app/lib/jobs"
+	"app/lib/palettes"
+	utils "app/lib/utils"
+	"fmt"
+	"net/http"
+
+	"github.com/disintegration/imaging"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// maxTrainingTiles caps how many labeled tiles the built-in classifier
+// samples, most recently updated first.
+const maxTrainingTiles = 50
+
+type classifyRequest struct {
+	// Classifier is a registered classifier name, the default when empty.
+	Classifier string `json:"classifier"`
+	// Palette is the id or name of the palette to classify into.
+	Palette string `json:"palette"`
+}
+
+// labeledExamples returns the satellite images of tiles whose landcover uses
+// palette, labeled with that landcover. Landcovers made by a classifier are
+// left out so the classifier doesn't learn from itself.
+func labeledExamples(app *pocketbase.PocketBase, landcoverPalettes *palettes.Store, palette *utils.LandcoverPalette) ([]classify.Example, error) {
+	tiles, err := app.Dao().FindRecordsByFilter("tiles", "satellite != '' && landcover.color != '' && landcover.classifier = ''", "-updated", maxTrainingTiles, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var examples []classify.Example
+	for _, tile := range tiles {
+		landcover, err := app.Dao().FindRecordById("landcovers", tile.GetString("landcover"))
+		if err != nil || landcoverPalettes.ForRecord(landcover) != palette {
+			continue
+		}
+		satellite, err := imaging.Open(utils.GetPathForFileField(tile, tile.Collection(), app.DataDir(), "satellite"))
+		if err != nil {
+			continue
+		}
+		labels, err := imaging.Open(utils.GetPathForFileField(landcover, landcover.Collection(), app.DataDir(), "color"))
+		if err != nil {
+			continue
+		}
+		examples = append(examples, classify.Example{Image: satellite, Labels: classMapForImage(labels, palette)})
+	}
+	return examples, nil
+}
+
+// onTileClassify handles POST /api/terrain/tiles/:id/classify. It creates a
+// landcover for the tile and queues the classification of the tile's
+// satellite image into it; the tile switches to it once that succeeds.
+func onTileClassify(c echo.Context, app *pocketbase.PocketBase, queue *jobs.Queue) error {
+	tile, err := app.Dao().FindRecordById("tiles", c.PathParam("id"))
+	if err != nil {
+		return apis.NewNotFoundError("", err)
+	}
+	if tile.GetString("satellite") == "" {
+		return apis.NewBadRequestError("The tile has no satellite image yet.", nil)
+	}
+
+	body := classifyRequest{}
+	if err := c.Bind(&body); err != nil {
+		return apis.NewBadRequestError("Failed to read the request body.", err)
+	}
+	classifier, err := classify.ClassifierForName(body.Classifier)
+	if err != nil {
+		return apis.NewBadRequestError("Invalid classifier.", err)
+	}
+
+	collection, err := app.Dao().FindCollectionByNameOrId("landcovers")
+	if err != nil {
+		return err
+	}
+	landcover := models.NewRecord(collection)
+	landcover.Set("classifier", classifier.Name())
+	landcover.Set("sourceTile", tile.Id)
+	if body.Palette != "" {
+		palette, err := app.Dao().FindFirstRecordByFilter("palettes", "id = {:palette} || name = {:palette}", dbx.Params{"palette": body.Palette})
+		if err != nil {
+			return apis.NewBadRequestError("Invalid palette.", err)
+		}
+		landcover.Set("palette", palette.Id)
+	}
+	if err := app.Dao().SaveRecord(landcover); err != nil {
+		return err
+	}
+
+	if _, err := queue.Enqueue("landcover.classify", landcover.Id); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusAccepted, landcover)
+}
+
+// onLandcoverClassify classifies the satellite image of a landcover's source
+// tile into its original image and processes it like an upload. It returns
+// the tile, which the caller points at the landcover once it is saved.
+func onLandcoverClassify(record *models.Record, app *pocketbase.PocketBase, palette *utils.LandcoverPalette) (*models.Record, error) {
+	tile, err := app.Dao().FindRecordById("tiles", record.GetString("sourceTile"))
+	if err != nil {
+		return nil, fmt.Errorf("source tile: %w", err)
+	}
+	classifier, err := classify.ClassifierForName(record.GetString("classifier"))
+	if err != nil {
+		return nil, err
+	}
+
+	satellite, err := imaging.Open(utils.GetPathForFileField(tile, tile.Collection(), app.DataDir(), "satellite"))
+	if err != nil {
+		return nil, err
+	}
+	classMap, err := classifier.Classify(satellite, palette)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", classifier.Name(), err)
+	}
+
+	originalPath := utils.GetFilePathForField(record, record.Collection(), app.DataDir(), "original")
+	if err := imaging.Save(imageForClassMap(classMap, palette), originalPath); err != nil {
+		return nil, err
+	}
+	record.Set("original", utils.GetFileNameForPath(originalPath))
+
+	if err := onLandcoverCreate(record, record.Collection(), app, palette); err != nil {
+		return nil, err
+	}
+	return tile, nil
+}
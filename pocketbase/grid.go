@@ -0,0 +1,227 @@
+package main
+
+import (
+	"app/lib/jobs"
+	"app/lib/palettes"
+	utils "app/lib/utils"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+const (
+	defaultGridSize = 100
+	minGridSize     = 2
+	maxGridSize     = 2048
+)
+
+// gridSpec is the simulation grid asked for by a tile or simulation: a fixed
+// number of cells per side, or cells of about CellSize meters.
+type gridSpec struct {
+	Size     int
+	CellSize float64
+}
+
+func gridSpecForRecord(record *models.Record) gridSpec {
+	return gridSpec{Size: record.GetInt("gridSize"), CellSize: record.GetFloat("gridCellSize")}
+}
+
+func (s gridSpec) isSet() bool {
+	return s.Size > 0 || s.CellSize > 0
+}
+
+// gridGeometry describes a grid laid over a tile. Cell sizes are in meters
+// and bbox is west, south, east, north.
+type gridGeometry struct {
+	Width      int       `json:"width"`
+	Height     int       `json:"height"`
+	CellWidth  float64   `json:"cellWidth"`
+	CellHeight float64   `json:"cellHeight"`
+	Bbox       []float64 `json:"bbox"`
+}
+
+// geometry lays the grid over a tile whose landcover is width x height
+// pixels. A cell size wins over a cell count; without either the grid is
+// defaultGridSize cells per side.
+func (s gridSpec) geometry(tile *models.Record, width int, height int) (gridGeometry, error) {
+	coords, err := utils.CoordsFromBboxString(tile.GetString("bbox"))
+	if err != nil {
+		return gridGeometry{}, fmt.Errorf("tile %s has no bbox: %w", tile.Id, err)
+	}
+	metersPerPixel := metersPerPixelForTile(tile, width)
+	groundWidth, groundHeight := metersPerPixel*float64(width), metersPerPixel*float64(height)
+
+	clamp := func(cells int) int {
+		return max(minGridSize, min(maxGridSize, cells))
+	}
+	geometry := gridGeometry{Width: defaultGridSize, Height: defaultGridSize, Bbox: coords}
+	switch {
+	case s.CellSize > 0 && metersPerPixel > 0:
+		geometry.Width = clamp(int(math.Round(groundWidth / s.CellSize)))
+		geometry.Height = clamp(int(math.Round(groundHeight / s.CellSize)))
+	case s.Size > 0:
+		geometry.Width = clamp(s.Size)
+		geometry.Height = clamp(s.Size)
+	}
+	geometry.CellWidth = groundWidth / float64(geometry.Width)
+	geometry.CellHeight = groundHeight / float64(geometry.Height)
+
+	return geometry, nil
+}
+
+// gridFields names the file fields a downsampled landcover is saved to.
+type gridFields struct {
+	Color    string
+	Texture  string
+	Coverage string
+}
+
+// saveGridRasters downsamples a landcover image to width x height cells and
+// saves the class colors, texture colors and per-cell coverage into the
+// fields of record. The color raster goes to colorPath.
+func saveGridRasters(record *models.Record, app *pocketbase.PocketBase, src image.Image, palette *utils.LandcoverPalette, width int, height int, colorPath string, fields gridFields) (*image.NRGBA, error) {
+	// every cell takes its most common class, or the class a priority rule
+	// picks, so small but important classes like water don't vanish
+	grid, coverage := classMapForImage(src, palette).Downsample(width, height, len(palette.Classes), downsampleRules(palette))
+	gridImage := imageForClassMap(grid, palette)
+	if err := imaging.Save(gridImage, colorPath, imaging.PNGCompressionLevel(-1)); err != nil {
+		return nil, err
+	}
+	record.Set(fields.Color, utils.GetFileNameForPath(colorPath))
+
+	collection := record.Collection()
+	texturePath := utils.GetFilePathForField(record, collection, app.DataDir(), fields.Texture)
+	if err := imaging.Save(utils.TextureImage(gridImage, palette), texturePath); err != nil {
+		return nil, err
+	}
+	record.Set(fields.Texture, utils.GetFileNameForPath(texturePath))
+
+	coveragePath := utils.GetFilePathForFieldWithExtension(record, collection, app.DataDir(), fields.Coverage, ".json")
+	if err := writeCellCoverage(coveragePath, coverage, palette); err != nil {
+		return nil, err
+	}
+	record.Set(fields.Coverage, utils.GetFileNameForPath(coveragePath))
+
+	return gridImage, nil
+}
+
+var tileGridFields = gridFields{Color: "grid_color", Texture: "grid_texture", Coverage: "grid_coverage"}
+
+// buildGrid downsamples the landcover of tile to the grid of spec and saves
+// the rasters and the grid geometry on record, a tile or a simulation.
+// Tiles without a processed landcover are left alone.
+func buildGrid(record *models.Record, tile *models.Record, spec gridSpec, app *pocketbase.PocketBase, landcoverPalettes *palettes.Store) error {
+	if tile.GetString("landcover") == "" {
+		return nil
+	}
+	landcover, err := app.Dao().FindRecordById("landcovers", tile.GetString("landcover"))
+	if err != nil {
+		return err
+	}
+	if landcover.GetString("color") == "" {
+		return nil
+	}
+
+	src, err := imaging.Open(utils.GetPathForFileField(landcover, landcover.Collection(), app.DataDir(), "color"))
+	if err != nil {
+		return err
+	}
+	geometry, err := spec.geometry(tile, src.Bounds().Dx(), src.Bounds().Dy())
+	if err != nil {
+		return err
+	}
+
+	colorPath := utils.GetFilePathForField(record, record.Collection(), app.DataDir(), tileGridFields.Color)
+	palette := landcoverPalettes.ForRecord(landcover)
+	if _, err := saveGridRasters(record, app, src, palette, geometry.Width, geometry.Height, colorPath, tileGridFields); err != nil {
+		return err
+	}
+
+	geometryJSON, err := json.Marshal(geometry)
+	if err != nil {
+		return err
+	}
+	record.Set("grid", string(geometryJSON))
+
+	return nil
+}
+
+// gridChanged reports whether an update touched the grid settings of record
+// or any of the other fields its grid depends on.
+func gridChanged(record *models.Record, fields ...string) bool {
+	original := record.OriginalCopy()
+	for _, field := range append([]string{"gridSize", "gridCellSize"}, fields...) {
+		if fmt.Sprint(record.Get(field)) != fmt.Sprint(original.Get(field)) {
+			return true
+		}
+	}
+	return false
+}
+
+// enqueueTileGrids rebuilds the grids of the tiles using a landcover.
+func enqueueTileGrids(app *pocketbase.PocketBase, queue *jobs.Queue, landcoverId string) error {
+	tiles, err := app.Dao().FindRecordsByFilter("tiles", "landcover = {:id}", "", 0, 0, dbx.Params{"id": landcoverId})
+	if err != nil {
+		return err
+	}
+	for _, tile := range tiles {
+		if _, err := queue.Enqueue("tile.grid", tile.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// onTileGrid builds the grid of a tile and of every simulation on it, since
+// they share its landcover.
+func onTileGrid(record *models.Record, app *pocketbase.PocketBase, landcoverPalettes *palettes.Store) error {
+	spec := gridSpecForRecord(record)
+	if err := buildGrid(record, record, spec, app, landcoverPalettes); err != nil {
+		return err
+	}
+
+	for _, id := range record.GetStringSlice("simulations") {
+		simulation, err := app.Dao().FindRecordById("simulations", id)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := onSimulationGrid(simulation, app, landcoverPalettes); err != nil {
+			return err
+		}
+		if err := app.Dao().SaveRecord(simulation); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// onSimulationGrid builds the grid of a simulation from the landcover of its
+// tile, with the simulation's grid settings or else the tile's.
+func onSimulationGrid(record *models.Record, app *pocketbase.PocketBase, landcoverPalettes *palettes.Store) error {
+	tile, err := app.Dao().FindFirstRecordByFilter("tiles", "simulations.id ?= {:id}", dbx.Params{"id": record.Id})
+	if errors.Is(err, sql.ErrNoRows) {
+		// not on a tile yet
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	spec := gridSpecForRecord(record)
+	if !spec.isSet() {
+		spec = gridSpecForRecord(tile)
+	}
+	return buildGrid(record, tile, spec, app, landcoverPalettes)
+}
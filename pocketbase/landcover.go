@@ -0,0 +1,147 @@
+package main
+
+import (
+	"app/lib/landscape"
+	"app/lib/mapbox"
+	"app/lib/tilemath"
+	utils "app/lib/utils"
+	"encoding/json"
+	"image"
+	"image/color"
+	"log"
+	"math"
+	"os"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// classMapForImage classifies every pixel of a landcover image with the
+// palette.
+func classMapForImage(img image.Image, palette *utils.LandcoverPalette) *landscape.ClassMap {
+	bounds := img.Bounds()
+	classMap := &landscape.ClassMap{
+		Width:   bounds.Dx(),
+		Height:  bounds.Dy(),
+		Indices: make([]int, 0, bounds.Dx()*bounds.Dy()),
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			classMap.Indices = append(classMap.Indices, palette.Index(color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)))
+		}
+	}
+	return classMap
+}
+
+// imageForClassMap paints every pixel of a class map with its class color.
+func imageForClassMap(classMap *landscape.ClassMap, palette *utils.LandcoverPalette) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, classMap.Width, classMap.Height))
+	for i, index := range classMap.Indices {
+		if index >= 0 {
+			img.SetNRGBA(i%classMap.Width, i/classMap.Width, palette.Classes[index].Color)
+		}
+	}
+	return img
+}
+
+// downsampleRules returns the priority rules of the palette's classes.
+func downsampleRules(palette *utils.LandcoverPalette) []landscape.Rule {
+	rules := make([]landscape.Rule, len(palette.Classes))
+	for i, class := range palette.Classes {
+		rules[i] = landscape.Rule{Priority: class.Priority, MinCoverage: class.MinCoverage}
+	}
+	return rules
+}
+
+// cellCoverage is the per-cell class coverage of a downsampled landcover,
+// with the names of the classes in the order of the fractions.
+type cellCoverage struct {
+	*landscape.Coverage
+	Names []string `json:"names"`
+}
+
+func writeCellCoverage(filePath string, coverage *landscape.Coverage, palette *utils.LandcoverPalette) error {
+	names := make([]string, len(palette.Classes))
+	for i, class := range palette.Classes {
+		names[i] = class.Name
+	}
+	data, err := json.Marshal(cellCoverage{Coverage: coverage, Names: names})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, 0644)
+}
+
+// landcoverCleanup reads the cleanup parameters of a landcover record. Areas
+// are in m², so the minimum mapping unit and hole filling are skipped while
+// the ground size of a pixel is unknown; the majority filter still applies.
+func landcoverCleanup(record *models.Record, app *pocketbase.PocketBase, width int) landscape.Cleanup {
+	cleanup := landscape.Cleanup{MajorityRadius: record.GetInt("majorityRadius")}
+
+	minMappingUnit, maxHoleArea := record.GetFloat("minMappingUnit"), record.GetFloat("maxHoleArea")
+	if minMappingUnit <= 0 && maxHoleArea <= 0 {
+		return cleanup
+	}
+	metersPerPixel := metersPerPixelForLandcover(record, app, width)
+	if metersPerPixel <= 0 {
+		log.Printf("Skipping the area cleanup of landcover %s: no tile gives it a ground resolution", record.Id)
+		return cleanup
+	}
+
+	pixelArea := metersPerPixel * metersPerPixel
+	cleanup.MinPatchPixels = int(math.Ceil(minMappingUnit / pixelArea))
+	cleanup.MaxHolePixels = int(math.Floor(maxHoleArea / pixelArea))
+	return cleanup
+}
+
+// metersPerPixelForLandcover returns the ground size of a pixel of a
+// landcover image width pixels wide, from the bbox of the tile using the
+// landcover or else the tile it was classified from, or 0 when there is none.
+func metersPerPixelForLandcover(record *models.Record, app *pocketbase.PocketBase, width int) float64 {
+	tile, err := app.Dao().FindFirstRecordByFilter("tiles", "landcover = {:id}", dbx.Params{"id": record.Id})
+	if err != nil {
+		if tile, err = app.Dao().FindRecordById("tiles", record.GetString("sourceTile")); err != nil {
+			return 0
+		}
+	}
+	return metersPerPixelForTile(tile, width)
+}
+
+// metersPerPixelForTile returns the ground size of a pixel of an image of the
+// tile width pixels wide, or 0 when the tile has no bbox.
+func metersPerPixelForTile(tile *models.Record, width int) float64 {
+	coords, err := utils.CoordsFromBboxString(tile.GetString("bbox"))
+	if err != nil || width == 0 {
+		return 0
+	}
+
+	// Web Mercator pixels are square on the ground, so the width is enough:
+	// the bbox spans a fraction of the single zoom 0 tile, one world wide
+	bbox := tilemath.BBox(coords)
+	_, lat := bbox.Center()
+	fraction := tilemath.LonToTile(bbox.East(), 0) - tilemath.LonToTile(bbox.West(), 0)
+	return mapbox.GroundResolution(0, lat, 1) * fraction / float64(width)
+}
+
+// landcoverMetrics computes the landscape metrics of a landcover image as
+// JSON, counting edges of the water class as shoreline.
+func landcoverMetrics(record *models.Record, app *pocketbase.PocketBase, img image.Image, palette *utils.LandcoverPalette) (string, error) {
+	classes := make([]landscape.Class, len(palette.Classes))
+	water := -1
+	for i, class := range palette.Classes {
+		classes[i] = landscape.Class{Name: class.Name, ClassId: class.ClassId}
+		if class.Name == "water" {
+			water = i
+		}
+	}
+
+	metersPerPixel := metersPerPixelForLandcover(record, app, img.Bounds().Dx())
+	metrics := landscape.Compute(classMapForImage(img, palette), classes, metersPerPixel, water)
+
+	metricsJSON, err := json.Marshal(metrics)
+	if err != nil {
+		return "", err
+	}
+	return string(metricsJSON), nil
+}
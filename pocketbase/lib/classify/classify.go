@@ -0,0 +1,121 @@
+package classify
+
+import (
+	"app/lib/landscape"
+	utils "app/lib/utils"
+	"fmt"
+	"image"
+	"os"
+	"sync"
+)
+
+// Classifier turns a satellite image into a class map over the classes of a
+// palette, one class index per pixel. Pixels it can't tell get -1.
+type Classifier interface {
+	Name() string
+	Classify(img image.Image, palette *utils.LandcoverPalette) (*landscape.ClassMap, error)
+}
+
+var (
+	classifiersMu sync.RWMutex
+	classifiers   = map[string]Classifier{}
+)
+
+// RegisterClassifier makes a classifier selectable by name, replacing any
+// classifier previously registered under the same name.
+func RegisterClassifier(name string, classifier Classifier) {
+	classifiersMu.Lock()
+	defer classifiersMu.Unlock()
+	classifiers[name] = classifier
+}
+
+// DefaultClassifierName is the classifier used when a request doesn't name
+// one, taken from CLASSIFIER and falling back to knn.
+func DefaultClassifierName() string {
+	if name := os.Getenv("CLASSIFIER"); name != "" {
+		return name
+	}
+	return "knn"
+}
+
+// ClassifierForName returns the registered classifier with the given name, or
+// the default classifier if name is empty.
+func ClassifierForName(name string) (Classifier, error) {
+	if name == "" {
+		name = DefaultClassifierName()
+	}
+
+	classifiersMu.RLock()
+	classifier, ok := classifiers[name]
+	classifiersMu.RUnlock()
+	if ok {
+		return classifier, nil
+	}
+
+	classifier = classifierFromEnv(name)
+	if classifier == nil {
+		return nil, fmt.Errorf("unknown classifier %q", name)
+	}
+	RegisterClassifier(name, classifier)
+
+	return classifier, nil
+}
+
+func classifierFromEnv(name string) Classifier {
+	switch name {
+	case "segment":
+		url := os.Getenv("SEGMENT_URL")
+		if url == "" {
+			return nil
+		}
+		return &SegmentClassifier{
+			URL:           url,
+			BoxThreshold:  utils.FloatFromEnv("SEGMENT_BOX_THRESHOLD", 0.25),
+			TextThreshold: utils.FloatFromEnv("SEGMENT_TEXT_THRESHOLD", 0.25),
+			Background:    os.Getenv("SEGMENT_BACKGROUND"),
+		}
+	case "stub":
+		return &Stub{Class: os.Getenv("STUB_CLASS")}
+	}
+	return nil
+}
+
+// Stub classifies every pixel as one class, the named one or else the first
+// of the palette. It stands in for a real classifier in development and tests.
+type Stub struct {
+	Class string
+}
+
+func (s *Stub) Name() string {
+	return "stub"
+}
+
+func (s *Stub) Classify(img image.Image, palette *utils.LandcoverPalette) (*landscape.ClassMap, error) {
+	if len(palette.Classes) == 0 {
+		return nil, fmt.Errorf("the palette has no classes")
+	}
+	class := 0
+	if s.Class != "" {
+		if class = classIndex(palette, s.Class); class < 0 {
+			return nil, fmt.Errorf("the palette has no class %q", s.Class)
+		}
+	}
+
+	bounds := img.Bounds()
+	classMap := &landscape.ClassMap{Width: bounds.Dx(), Height: bounds.Dy(), Indices: make([]int, bounds.Dx()*bounds.Dy())}
+	for i := range classMap.Indices {
+		classMap.Indices[i] = class
+	}
+	return classMap, nil
+}
+
+// classIndex returns the index of the palette class with the given name, or
+// -1 if there is none.
+func classIndex(palette *utils.LandcoverPalette, name string) int {
+	for i, class := range palette.Classes {
+		if class.Name == name {
+			return i
+		}
+	}
+	return -1
+}
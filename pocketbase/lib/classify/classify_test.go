@@ -0,0 +1,109 @@
+package classify
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	utils "app/lib/utils"
+)
+
+func testPalette(t *testing.T) *utils.LandcoverPalette {
+	t.Helper()
+	palette, err := utils.NewLandcoverPalette([]utils.Landcover{
+		{Name: "water", Color: color.NRGBA{R: 65, G: 155, B: 223, A: 255}},
+		{Name: "trees", Color: color.NRGBA{R: 57, G: 125, B: 73, A: 255}},
+		{Name: "bare_ground", Color: color.NRGBA{R: 165, G: 155, B: 143, A: 255}},
+	}, utils.DistanceRGB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return palette
+}
+
+// halves returns an image whose left half is left and right half is right.
+func halves(width int, height int, left color.NRGBA, right color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if x < width/2 {
+				img.SetNRGBA(x, y, left)
+			} else {
+				img.SetNRGBA(x, y, right)
+			}
+		}
+	}
+	return img
+}
+
+func TestStub(t *testing.T) {
+	palette := testPalette(t)
+	img := image.NewNRGBA(image.Rect(3, 4, 7, 6))
+
+	tests := []struct {
+		class string
+		want  int
+	}{
+		{"", 0},
+		{"trees", 1},
+		{"bare_ground", 2},
+	}
+	for _, test := range tests {
+		classMap, err := (&Stub{Class: test.class}).Classify(img, palette)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if classMap.Width != 4 || classMap.Height != 2 || len(classMap.Indices) != 8 {
+			t.Fatalf("class map is %dx%d with %d indices, want 4x2 with 8", classMap.Width, classMap.Height, len(classMap.Indices))
+		}
+		for i, class := range classMap.Indices {
+			if class != test.want {
+				t.Errorf("Stub{%q}: pixel %d is class %d, want %d", test.class, i, class, test.want)
+			}
+		}
+	}
+
+	if _, err := (&Stub{Class: "snow"}).Classify(img, palette); err == nil {
+		t.Error("expected an error for a class the palette lacks")
+	}
+	empty, err := utils.NewLandcoverPalette(nil, utils.DistanceRGB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&Stub{}).Classify(img, empty); err == nil {
+		t.Error("expected an error for an empty palette")
+	}
+}
+
+func TestClassifierForName(t *testing.T) {
+	t.Setenv("CLASSIFIER", "")
+	t.Setenv("STUB_CLASS", "trees")
+	t.Setenv("SEGMENT_URL", "")
+
+	registered := &Stub{Class: "water"}
+	RegisterClassifier("test-registered", registered)
+	if got, err := ClassifierForName("test-registered"); err != nil || got != registered {
+		t.Errorf("ClassifierForName(test-registered) = %v, %v, want the registered classifier", got, err)
+	}
+
+	stub, err := ClassifierForName("stub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := stub.(*Stub).Class; got != "trees" {
+		t.Errorf("stub class %q, want trees from STUB_CLASS", got)
+	}
+
+	// segment needs a server to talk to
+	if _, err := ClassifierForName("segment"); err == nil {
+		t.Error("expected an error for segment without SEGMENT_URL")
+	}
+	if _, err := ClassifierForName("nope"); err == nil {
+		t.Error("expected an error for an unknown classifier")
+	}
+
+	t.Setenv("CLASSIFIER", "test-registered")
+	if got, err := ClassifierForName(""); err != nil || got != registered {
+		t.Errorf("ClassifierForName(\"\") = %v, %v, want the CLASSIFIER default", got, err)
+	}
+}
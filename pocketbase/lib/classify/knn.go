@@ -0,0 +1,152 @@
+package classify
+
+import (
+	"app/lib/landscape"
+	utils "app/lib/utils"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Example is a labeled satellite image: Labels holds the class of every
+// pixel, at the image's size or any other.
+type Example struct {
+	Image  image.Image
+	Labels *landscape.ClassMap
+}
+
+// KNN is a pixel classifier voting among the K training pixels closest in
+// CIELAB. The training pixels are sampled from the examples Examples returns
+// for the palette at every call, so newly labeled tiles count right away.
+type KNN struct {
+	K          int
+	MaxSamples int
+	Examples   func(palette *utils.LandcoverPalette) ([]Example, error)
+}
+
+type sample struct {
+	lab   utils.Lab
+	class int
+}
+
+func (k *KNN) Name() string {
+	return "knn"
+}
+
+func (k *KNN) Classify(img image.Image, palette *utils.LandcoverPalette) (*landscape.ClassMap, error) {
+	examples, err := k.Examples(palette)
+	if err != nil {
+		return nil, err
+	}
+	samples := k.samples(examples, len(palette.Classes))
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no labeled tiles to train on")
+	}
+
+	bounds := img.Bounds()
+	classMap := &landscape.ClassMap{Width: bounds.Dx(), Height: bounds.Dy(), Indices: make([]int, 0, bounds.Dx()*bounds.Dy())}
+
+	// neighbors are looked up once per 15-bit color, which is far finer than
+	// the differences between classes
+	votes := map[uint16]int{}
+	distances := make([]float64, len(samples))
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			key := uint16(c.R>>3)<<10 | uint16(c.G>>3)<<5 | uint16(c.B>>3)
+			class, ok := votes[key]
+			if !ok {
+				quantized := color.NRGBA{R: c.R&^7 | 4, G: c.G&^7 | 4, B: c.B&^7 | 4, A: 255}
+				class = k.vote(samples, distances, utils.RgbToLab(quantized), len(palette.Classes))
+				votes[key] = class
+			}
+			classMap.Indices = append(classMap.Indices, class)
+		}
+	}
+
+	return classMap, nil
+}
+
+// samples picks up to MaxSamples labeled pixels spread evenly over the
+// examples, skipping unlabeled ones.
+func (k *KNN) samples(examples []Example, classes int) []sample {
+	total := 0
+	for _, example := range examples {
+		total += example.Image.Bounds().Dx() * example.Image.Bounds().Dy()
+	}
+	maxSamples := k.MaxSamples
+	if maxSamples <= 0 {
+		maxSamples = 10000
+	}
+	// rows and columns round up per example, so the estimate can overshoot
+	stride := max(1, int(math.Ceil(math.Sqrt(float64(total)/float64(maxSamples)))))
+	for sampled(examples, stride) > maxSamples {
+		stride++
+	}
+
+	var samples []sample
+	for _, example := range examples {
+		bounds := example.Image.Bounds()
+		labels := example.Labels
+		for y := 0; y < bounds.Dy(); y += stride {
+			for x := 0; x < bounds.Dx(); x += stride {
+				class := labels.At(x*labels.Width/bounds.Dx(), y*labels.Height/bounds.Dy())
+				if class < 0 || class >= classes {
+					continue
+				}
+				c := color.NRGBAModel.Convert(example.Image.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+				if c.A == 0 {
+					continue
+				}
+				samples = append(samples, sample{lab: utils.RgbToLab(c), class: class})
+			}
+		}
+	}
+	return samples
+}
+
+// sampled returns how many pixels of the examples a stride visits.
+func sampled(examples []Example, stride int) int {
+	n := 0
+	for _, example := range examples {
+		bounds := example.Image.Bounds()
+		n += (bounds.Dx() + stride - 1) / stride * ((bounds.Dy() + stride - 1) / stride)
+	}
+	return n
+}
+
+// vote returns the most common class among the K samples nearest to lab,
+// breaking ties in favor of the nearest. distances is scratch space with room
+// for one distance per sample, reused across calls.
+func (k *KNN) vote(samples []sample, distances []float64, lab utils.Lab, classes int) int {
+	K := max(1, min(k.K, len(samples)))
+	nearest := make([]int, 0, K)
+	for i, s := range samples {
+		distances[i] = utils.CIE76(lab, s.lab)
+
+		// insertion into the K nearest so far
+		if len(nearest) == K && distances[i] >= distances[nearest[K-1]] {
+			continue
+		}
+		if len(nearest) < K {
+			nearest = append(nearest, i)
+		} else {
+			nearest[K-1] = i
+		}
+		for j := len(nearest) - 1; j > 0 && distances[nearest[j]] < distances[nearest[j-1]]; j-- {
+			nearest[j], nearest[j-1] = nearest[j-1], nearest[j]
+		}
+	}
+
+	counts := make([]int, classes)
+	best := samples[nearest[0]].class
+	for _, i := range nearest {
+		class := samples[i].class
+		counts[class]++
+		if counts[class] > counts[best] {
+			best = class
+		}
+	}
+	return best
+}
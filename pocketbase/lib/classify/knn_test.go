@@ -0,0 +1,112 @@
+package classify
+
+import (
+	"errors"
+	"image/color"
+	"testing"
+
+	"app/lib/landscape"
+	utils "app/lib/utils"
+)
+
+var (
+	lake   = color.NRGBA{R: 30, G: 70, B: 140, A: 255}
+	forest = color.NRGBA{R: 35, G: 90, B: 40, A: 255}
+)
+
+// lakeAndForest is a 40x20 example, lake on the left and forest on the
+// right, with labels at half the size of the image.
+func lakeAndForest() Example {
+	labels := &landscape.ClassMap{Width: 20, Height: 10, Indices: make([]int, 200)}
+	for i := range labels.Indices {
+		if i%20 >= 10 {
+			labels.Indices[i] = 1
+		}
+	}
+	return Example{Image: halves(40, 20, lake, forest), Labels: labels}
+}
+
+func TestKNNClassifiesLikeTheExamples(t *testing.T) {
+	palette := testPalette(t)
+	knn := &KNN{K: 3, MaxSamples: 100, Examples: func(*utils.LandcoverPalette) ([]Example, error) {
+		return []Example{lakeAndForest()}, nil
+	}}
+
+	// slightly off colors, swapped sides
+	img := halves(8, 2, color.NRGBA{R: 40, G: 95, B: 45, A: 255}, color.NRGBA{R: 25, G: 65, B: 150, A: 255})
+	classMap, err := knn.Classify(img, palette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if classMap.Width != 8 || classMap.Height != 2 {
+		t.Fatalf("class map is %dx%d, want 8x2", classMap.Width, classMap.Height)
+	}
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 8; x++ {
+			want := 0
+			if x < 4 {
+				want = 1
+			}
+			if got := classMap.At(x, y); got != want {
+				t.Errorf("pixel %d,%d is class %d, want %d", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestKNNSamplesAtMostMaxSamples(t *testing.T) {
+	knn := &KNN{K: 1, MaxSamples: 50}
+	samples := knn.samples([]Example{lakeAndForest(), lakeAndForest()}, 3)
+	if len(samples) == 0 || len(samples) > 50 {
+		t.Errorf("%d samples, want 1..50", len(samples))
+	}
+}
+
+func TestKNNNeedsExamples(t *testing.T) {
+	palette := testPalette(t)
+	img := halves(2, 2, lake, forest)
+
+	none := &KNN{K: 3, Examples: func(*utils.LandcoverPalette) ([]Example, error) {
+		return nil, nil
+	}}
+	if _, err := none.Classify(img, palette); err == nil {
+		t.Error("expected an error without labeled tiles")
+	}
+
+	// labels outside the palette don't count as training data
+	unlabeled := lakeAndForest()
+	for i := range unlabeled.Labels.Indices {
+		unlabeled.Labels.Indices[i] = -1
+	}
+	outside := &KNN{K: 3, Examples: func(*utils.LandcoverPalette) ([]Example, error) {
+		return []Example{unlabeled}, nil
+	}}
+	if _, err := outside.Classify(img, palette); err == nil {
+		t.Error("expected an error when no pixel is labeled")
+	}
+
+	broken := errors.New("database is gone")
+	failing := &KNN{K: 3, Examples: func(*utils.LandcoverPalette) ([]Example, error) {
+		return nil, broken
+	}}
+	if _, err := failing.Classify(img, palette); !errors.Is(err, broken) {
+		t.Errorf("err = %v, want %v", err, broken)
+	}
+}
+
+func TestVoteBreaksTiesTowardsTheNearest(t *testing.T) {
+	knn := &KNN{K: 2}
+	samples := []sample{
+		{lab: utils.Lab{L: 50}, class: 2},
+		{lab: utils.Lab{L: 52}, class: 1},
+		{lab: utils.Lab{L: 90}, class: 0},
+	}
+	distances := make([]float64, len(samples))
+	if got := knn.vote(samples, distances, utils.Lab{L: 51.5}, 3); got != 1 {
+		t.Errorf("vote = %d, want 1", got)
+	}
+	// the scratch buffer carries nothing over between calls
+	if got := knn.vote(samples, distances, utils.Lab{L: 50.5}, 3); got != 2 {
+		t.Errorf("vote = %d, want 2", got)
+	}
+}
@@ -0,0 +1,134 @@
+package classify
+
+import (
+	"app/lib/landscape"
+	utils "app/lib/utils"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+// SegmentClassifier classifies with an external text-prompted segmentation
+// server like the /segment endpoint of api.py. It asks for a mask of every
+// class of the palette by name; the first class whose mask covers a pixel
+// wins and pixels no mask covers get the Background class, or -1.
+type SegmentClassifier struct {
+	URL           string
+	Client        *http.Client
+	BoxThreshold  float64
+	TextThreshold float64
+	Background    string
+}
+
+func (s *SegmentClassifier) Name() string {
+	return "segment"
+}
+
+func (s *SegmentClassifier) Classify(img image.Image, palette *utils.LandcoverPalette) (*landscape.ClassMap, error) {
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, img); err != nil {
+		return nil, err
+	}
+
+	background := -1
+	if s.Background != "" {
+		if background = classIndex(palette, s.Background); background < 0 {
+			return nil, fmt.Errorf("the palette has no class %q", s.Background)
+		}
+	}
+
+	bounds := img.Bounds()
+	classMap := &landscape.ClassMap{Width: bounds.Dx(), Height: bounds.Dy(), Indices: make([]int, bounds.Dx()*bounds.Dy())}
+	for i := range classMap.Indices {
+		classMap.Indices[i] = -1
+	}
+
+	for i, class := range palette.Classes {
+		mask, err := s.segment(encoded.Bytes(), strings.ReplaceAll(class.Name, "_", " "))
+		if err != nil {
+			return nil, fmt.Errorf("segment %s: %w", class.Name, err)
+		}
+		if mask.Bounds().Dx() != bounds.Dx() || mask.Bounds().Dy() != bounds.Dy() {
+			mask = imaging.Resize(mask, bounds.Dx(), bounds.Dy(), imaging.NearestNeighbor)
+		}
+
+		// the mask is white where the class was found, JPEG noise aside
+		maskBounds := mask.Bounds()
+		for y := 0; y < bounds.Dy(); y++ {
+			for x := 0; x < bounds.Dx(); x++ {
+				if classMap.Indices[y*bounds.Dx()+x] >= 0 {
+					continue
+				}
+				gray := color.GrayModel.Convert(mask.At(maskBounds.Min.X+x, maskBounds.Min.Y+y)).(color.Gray)
+				if gray.Y >= 128 {
+					classMap.Indices[y*bounds.Dx()+x] = i
+				}
+			}
+		}
+	}
+
+	for i, class := range classMap.Indices {
+		if class < 0 {
+			classMap.Indices[i] = background
+		}
+	}
+
+	return classMap, nil
+}
+
+// segment posts the image with a prompt and returns the mask image.
+func (s *SegmentClassifier) segment(encoded []byte, prompt string) (image.Image, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile("image", "satellite.png")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Write(encoded); err != nil {
+		return nil, err
+	}
+	options, err := json.Marshal(map[string]any{
+		"prompt":         prompt,
+		"box_threshold":  s.BoxThreshold,
+		"text_threshold": s.TextThreshold,
+		"only_mask":      true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := form.WriteField("json_data", string(options)); err != nil {
+		return nil, err
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	resp, err := client.Post(s.URL, form.FormDataContentType(), &body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	mask, _, err := image.Decode(resp.Body)
+	return mask, err
+}
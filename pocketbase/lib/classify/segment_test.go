@@ -0,0 +1,170 @@
+package classify
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	utils "app/lib/utils"
+)
+
+// segmentStandIn answers /segment like api.py: a mask of the left half for
+// water, the right half for trees and nothing for other prompts. It records
+// the prompts it got.
+func segmentStandIn(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/segment" || r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		file, _, err := r.FormFile("image")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		img, err := png.Decode(file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		options := struct {
+			Prompt   string `json:"prompt"`
+			OnlyMask bool   `json:"only_mask"`
+		}{}
+		if err := json.Unmarshal([]byte(r.FormValue("json_data")), &options); err != nil || !options.OnlyMask {
+			http.Error(w, "bad json_data", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		prompts = append(prompts, options.Prompt)
+		mu.Unlock()
+
+		black, white := color.NRGBA{A: 255}, color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+		// masks come back at half the size to exercise the resize
+		width, height := img.Bounds().Dx()/2, img.Bounds().Dy()/2
+		var mask image.Image
+		switch options.Prompt {
+		case "water":
+			mask = halves(width, height, white, black)
+		case "trees":
+			mask = halves(width, height, black, white)
+		default:
+			mask = halves(width, height, black, black)
+		}
+		w.Header().Set("Content-Type", "image/png")
+		png.Encode(w, mask)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), prompts...)
+	}
+}
+
+func TestSegmentClassifier(t *testing.T) {
+	server, prompts := segmentStandIn(t)
+	palette := testPalette(t)
+	segment := &SegmentClassifier{URL: server.URL + "/segment", BoxThreshold: 0.3, TextThreshold: 0.25}
+
+	classMap, err := segment.Classify(image.NewNRGBA(image.Rect(0, 0, 8, 4)), palette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 8; x++ {
+			want := 1
+			if x < 4 {
+				want = 0
+			}
+			if got := classMap.At(x, y); got != want {
+				t.Errorf("pixel %d,%d is class %d, want %d", x, y, got, want)
+			}
+		}
+	}
+
+	// underscores in class names become spaces in the prompt
+	if got := strings.Join(prompts(), ","); got != "water,trees,bare ground" {
+		t.Errorf("prompts = %s, want water,trees,bare ground", got)
+	}
+}
+
+func TestSegmentClassifierBackground(t *testing.T) {
+	server, _ := segmentStandIn(t)
+	classes := testPalette(t).Classes
+	segment := &SegmentClassifier{URL: server.URL + "/segment", Background: "bare_ground"}
+
+	waterOnly, err := utils.NewLandcoverPalette(classes[:1], utils.DistanceRGB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := segment.Classify(image.NewNRGBA(image.Rect(0, 0, 4, 2)), waterOnly); err == nil {
+		t.Error("expected an error for a background class the palette lacks")
+	}
+
+	// a palette without trees leaves the right half to the background
+	palette, err := utils.NewLandcoverPalette([]utils.Landcover{classes[0], classes[2]}, utils.DistanceRGB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	classMap, err := segment.Classify(image.NewNRGBA(image.Rect(0, 0, 4, 2)), palette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := classMap.At(0, 0); got != 0 {
+		t.Errorf("left is class %d, want water", got)
+	}
+	if got := classMap.At(3, 1); got != 1 {
+		t.Errorf("right is class %d, want the bare_ground background", got)
+	}
+}
+
+func TestSegmentClassifierReportsServerErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model not loaded", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	segment := &SegmentClassifier{URL: server.URL + "/segment"}
+	_, err := segment.Classify(image.NewNRGBA(image.Rect(0, 0, 2, 2)), testPalette(t))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"water", "503", "model not loaded"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't mention %q", err, want)
+		}
+	}
+}
+
+func TestSegmentClassifierTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	segment := &SegmentClassifier{URL: server.URL + "/segment", Client: &http.Client{Timeout: 50 * time.Millisecond}}
+	start := time.Now()
+	if _, err := segment.Classify(image.NewNRGBA(image.Rect(0, 0, 2, 2)), testPalette(t)); err == nil {
+		t.Fatal("expected a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Classify returned after %v", elapsed)
+	}
+}
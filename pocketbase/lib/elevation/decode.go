@@ -0,0 +1,66 @@
+package elevation
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// Encoding describes how elevation is packed into the pixels of an image.
+type Encoding string
+
+const (
+	// EncodingTerrainRGB is Mapbox terrain-rgb: -10000 + (R*256*256 + G*256 + B) * 0.1
+	EncodingTerrainRGB Encoding = "terrain-rgb"
+	// EncodingTerrarium is Mapzen/AWS Terrarium: R*256 + G + B/256 - 32768
+	EncodingTerrarium Encoding = "terrarium"
+	// EncodingGray16 is a 16-bit grayscale DEM holding whole meters.
+	EncodingGray16 Encoding = "gray16"
+)
+
+func TerrainRGB(r uint8, g uint8, b uint8) float64 {
+	return -10000 + float64(int(r)*256*256+int(g)*256+int(b))*0.1
+}
+
+func Terrarium(r uint8, g uint8, b uint8) float64 {
+	return float64(r)*256 + float64(g) + float64(b)/256 - 32768
+}
+
+// Decode converts an elevation image to a grid of meters.
+func Decode(img image.Image, encoding Encoding) (*Grid, error) {
+	if img == nil {
+		return nil, fmt.Errorf("no image to decode")
+	}
+
+	var decodePixel func(c color.Color) float64
+	switch encoding {
+	case EncodingTerrainRGB, "":
+		decodePixel = func(c color.Color) float64 {
+			// the channels from RGBA() are 16-bit and premultiplied, so go
+			// through NRGBA to get the 8-bit values the encoding is defined on
+			p := color.NRGBAModel.Convert(c).(color.NRGBA)
+			return TerrainRGB(p.R, p.G, p.B)
+		}
+	case EncodingTerrarium:
+		decodePixel = func(c color.Color) float64 {
+			p := color.NRGBAModel.Convert(c).(color.NRGBA)
+			return Terrarium(p.R, p.G, p.B)
+		}
+	case EncodingGray16:
+		decodePixel = func(c color.Color) float64 {
+			return float64(color.Gray16Model.Convert(c).(color.Gray16).Y)
+		}
+	default:
+		return nil, fmt.Errorf("unknown elevation encoding %q", encoding)
+	}
+
+	bounds := img.Bounds()
+	grid := NewGrid(bounds.Dx(), bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			grid.Set(x-bounds.Min.X, y-bounds.Min.Y, decodePixel(img.At(x, y)))
+		}
+	}
+
+	return grid, nil
+}
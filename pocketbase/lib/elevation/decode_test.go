@@ -0,0 +1,149 @@
+package elevation
+
+import (
+	"image"
+	"image/color"
+	_ "image/png"
+	"math"
+	"os"
+	"testing"
+)
+
+const sampleTile = "../../../api/elevation_tile.png"
+
+func openSample(t *testing.T) image.Image {
+	t.Helper()
+	file, err := os.Open(sampleTile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// assertHeight compares at the float32 precision the grid stores heights in.
+func assertHeight(t *testing.T, grid *Grid, x int, y int, want float64) {
+	t.Helper()
+	if got := grid.At(x, y); math.Abs(got-float64(float32(want))) > 1e-3 {
+		t.Errorf("height at %d,%d = %v, want %v", x, y, got, want)
+	}
+}
+
+// The sample tile is 8-bit gray, so every encoding reads the same byte v in
+// all three channels: 177 at 0,0 and 99,99, 162 at 50,50 and 207 at 10,80.
+func TestDecodeSampleTile(t *testing.T) {
+	img := openSample(t)
+
+	tests := []struct {
+		encoding Encoding
+		heights  map[[2]int]float64
+	}{
+		{EncodingTerrainRGB, map[[2]int]float64{
+			// -10000 + v*65793*0.1
+			{0, 0}:   1154536.1,
+			{99, 99}: 1154536.1,
+			{50, 50}: 1055846.6,
+			{10, 80}: 1351915.1,
+		}},
+		{EncodingTerrarium, map[[2]int]float64{
+			// v*257 + v/256 - 32768
+			{0, 0}:   12721.69140625,
+			{50, 50}: 8866.6328125,
+			{10, 80}: 20431.80859375,
+		}},
+		{EncodingGray16, map[[2]int]float64{
+			// 8-bit gray widens to v*257
+			{0, 0}:   45489,
+			{50, 50}: 41634,
+			{10, 80}: 53199,
+		}},
+	}
+	for _, test := range tests {
+		t.Run(string(test.encoding), func(t *testing.T) {
+			grid, err := Decode(img, test.encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if grid.Width != 100 || grid.Height != 100 {
+				t.Fatalf("grid is %dx%d, want 100x100", grid.Width, grid.Height)
+			}
+			for p, want := range test.heights {
+				assertHeight(t, grid, p[0], p[1], want)
+			}
+		})
+	}
+}
+
+func TestDecodeKnownPixels(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding Encoding
+		pixel    color.Color
+		want     float64
+	}{
+		{"terrain-rgb sea level", EncodingTerrainRGB, color.NRGBA{R: 1, G: 134, B: 160, A: 255}, 0},
+		{"terrain-rgb everest", EncodingTerrainRGB, color.NRGBA{R: 2, G: 224, B: 67, A: 255}, 8848.3},
+		{"terrain-rgb lowest", EncodingTerrainRGB, color.NRGBA{A: 255}, -10000},
+		{"terrarium sea level", EncodingTerrarium, color.NRGBA{R: 128, A: 255}, 0},
+		{"terrarium fraction", EncodingTerrarium, color.NRGBA{R: 130, G: 4, B: 128, A: 255}, 516.5},
+		{"terrarium below sea", EncodingTerrarium, color.NRGBA{R: 127, G: 246, A: 255}, -10},
+		{"gray16", EncodingGray16, color.Gray16{Y: 4321}, 4321},
+		{"gray16 max", EncodingGray16, color.Gray16{Y: 65535}, 65535},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+			var src image.Image = img
+			if gray, ok := test.pixel.(color.Gray16); ok {
+				g := image.NewGray16(image.Rect(0, 0, 1, 1))
+				g.SetGray16(0, 0, gray)
+				src = g
+			} else {
+				img.Set(0, 0, test.pixel)
+			}
+
+			grid, err := Decode(src, test.encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertHeight(t, grid, 0, 0, test.want)
+		})
+	}
+}
+
+// Regression test: heights used to be computed from the 16-bit, premultiplied
+// channels of RGBA(), which is off by orders of magnitude for opaque pixels
+// and shifts with alpha for translucent ones.
+func TestDecodeUsesStraightEightBitChannels(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 1, G: 134, B: 160, A: 255})
+	img.SetNRGBA(1, 0, color.NRGBA{R: 1, G: 134, B: 160, A: 254})
+
+	grid, err := Decode(img, EncodingTerrainRGB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertHeight(t, grid, 0, 0, 0)
+
+	// what the old decoder produced for the opaque pixel
+	r, g, b, _ := img.At(0, 0).RGBA()
+	if old := -10000 + float64(r*256*256+g*256+b)*0.1; math.Abs(old-grid.At(0, 0)) < 1 {
+		t.Fatalf("old decoding %v matches the new one, the test doesn't cover the bug", old)
+	}
+
+	// premultiplying by 254/255 would move the height by about 390 m
+	assertHeight(t, grid, 1, 0, 0)
+}
+
+func TestDecodeRejectsUnknownEncoding(t *testing.T) {
+	if _, err := Decode(image.NewGray(image.Rect(0, 0, 1, 1)), "png8"); err == nil {
+		t.Error("expected an error for an unknown encoding")
+	}
+	if _, err := Decode(nil, EncodingTerrainRGB); err == nil {
+		t.Error("expected an error for a nil image")
+	}
+}
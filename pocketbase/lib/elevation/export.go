@@ -0,0 +1,90 @@
+package elevation
+
+import (
+	"encoding/binary"
+	"image"
+	"io"
+	"math"
+)
+
+// Resample returns the grid scaled to width x height with bilinear
+// interpolation. The corners of the new grid sample the centers of the
+// corner pixels, which is how terrain engines expect 2^n+1 heightmaps to line
+// up with neighbors.
+func (g *Grid) Resample(width int, height int) *Grid {
+	resampled := NewGrid(width, height)
+	for y := 0; y < height; y++ {
+		fy := 0.5
+		if height > 1 {
+			fy += float64(y) * float64(g.Height-1) / float64(height-1)
+		}
+		for x := 0; x < width; x++ {
+			fx := 0.5
+			if width > 1 {
+				fx += float64(x) * float64(g.Width-1) / float64(width-1)
+			}
+			resampled.Set(x, y, g.Sample(fx, fy))
+		}
+	}
+	return resampled
+}
+
+// Uint16 maps value from [min, max] meters to the full uint16 range.
+func Uint16(value float64, min float64, max float64) uint16 {
+	if max <= min {
+		return 0
+	}
+	normalized := (value - min) / (max - min)
+	return uint16(math.Round(math.Max(0, math.Min(1, normalized)) * math.MaxUint16))
+}
+
+// Gray16 returns the grid as a 16-bit grayscale image where black is min and
+// white is max meters.
+func (g *Grid) Gray16(min float64, max float64) *image.Gray16 {
+	img := image.NewGray16(image.Rect(0, 0, g.Width, g.Height))
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			value := Uint16(g.At(x, y), min, max)
+			offset := img.PixOffset(x, y)
+			img.Pix[offset] = uint8(value >> 8)
+			img.Pix[offset+1] = uint8(value)
+		}
+	}
+	return img
+}
+
+// WriteR16 writes the grid as little-endian uint16 values, the RAW layout
+// Unity imports. Unity reads the first row as the southern edge, so rows are
+// written from south to north unless northFirst is set.
+func (g *Grid) WriteR16(w io.Writer, min float64, max float64, northFirst bool) error {
+	row := make([]uint16, g.Width)
+	for i := 0; i < g.Height; i++ {
+		y := g.Height - 1 - i
+		if northFirst {
+			y = i
+		}
+		for x := range row {
+			row[x] = Uint16(g.At(x, y), min, max)
+		}
+		if err := binary.Write(w, binary.LittleEndian, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EngineSize returns the smallest 2^n+1 size (33 to 4097) that holds size
+// pixels, the sizes Unity and Unreal accept for terrain heightmaps.
+func EngineSize(size int) int {
+	for n := 32; n < 4096; n *= 2 {
+		if n+1 >= size {
+			return n + 1
+		}
+	}
+	return 4097
+}
+
+// IsEngineSize reports whether size is a 2^n+1 size between 33 and 4097.
+func IsEngineSize(size int) bool {
+	return size >= 33 && size <= 4097 && (size-1)&(size-2) == 0
+}
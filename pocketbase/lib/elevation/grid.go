@@ -0,0 +1,116 @@
+package elevation
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+)
+
+// Grid is a raster of elevations in meters, stored row by row from the
+// north-west corner.
+type Grid struct {
+	Width  int
+	Height int
+	Values []float32
+}
+
+// Info describes a grid written with WriteFile. It is stored next to the
+// raw file so readers know how to interpret it.
+type Info struct {
+	Width     int     `json:"width"`
+	Height    int     `json:"height"`
+	Type      string  `json:"type"`
+	ByteOrder string  `json:"byteOrder"`
+	Unit      string  `json:"unit"`
+	Min       float64 `json:"min"`
+	Max       float64 `json:"max"`
+}
+
+func NewGrid(width int, height int) *Grid {
+	return &Grid{
+		Width:  width,
+		Height: height,
+		Values: make([]float32, width*height),
+	}
+}
+
+func (g *Grid) At(x int, y int) float64 {
+	return float64(g.Values[y*g.Width+x])
+}
+
+func (g *Grid) Set(x int, y int, value float64) {
+	g.Values[y*g.Width+x] = float32(value)
+}
+
+func (g *Grid) MinMax() (float64, float64) {
+	min := math.Inf(1)
+	max := math.Inf(-1)
+	for _, value := range g.Values {
+		min = math.Min(min, float64(value))
+		max = math.Max(max, float64(value))
+	}
+	return min, max
+}
+
+func (g *Grid) Info() Info {
+	min, max := g.MinMax()
+	return Info{
+		Width:     g.Width,
+		Height:    g.Height,
+		Type:      "float32",
+		ByteOrder: "little",
+		Unit:      "m",
+		Min:       min,
+		Max:       max,
+	}
+}
+
+// Sample returns the bilinearly interpolated elevation at fractional pixel
+// coordinates, where (0.5, 0.5) is the center of the first pixel.
+func (g *Grid) Sample(fx float64, fy float64) float64 {
+	fx = math.Max(0, math.Min(float64(g.Width)-1, fx-0.5))
+	fy = math.Max(0, math.Min(float64(g.Height)-1, fy-0.5))
+
+	x0, y0 := int(fx), int(fy)
+	x1, y1 := min(x0+1, g.Width-1), min(y0+1, g.Height-1)
+	tx, ty := fx-float64(x0), fy-float64(y0)
+
+	top := g.At(x0, y0)*(1-tx) + g.At(x1, y0)*tx
+	bottom := g.At(x0, y1)*(1-tx) + g.At(x1, y1)*tx
+	return top*(1-ty) + bottom*ty
+}
+
+// WriteFile writes the grid as raw little-endian float32 values.
+func (g *Grid) WriteFile(filePath string) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return binary.Write(file, binary.LittleEndian, g.Values)
+}
+
+// ReadGrid reads a grid written by WriteFile.
+func ReadGrid(filePath string, info Info) (*Grid, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if stat.Size() != int64(info.Width*info.Height*4) {
+		return nil, fmt.Errorf("elevation file %s is %d bytes, expected %dx%d float32 values", filePath, stat.Size(), info.Width, info.Height)
+	}
+
+	grid := NewGrid(info.Width, info.Height)
+	if err := binary.Read(file, binary.LittleEndian, grid.Values); err != nil {
+		return nil, err
+	}
+	return grid, nil
+}
@@ -0,0 +1,125 @@
+package elevation
+
+import (
+	"image"
+	"math"
+)
+
+// Gradient returns the rate of change in elevation towards the east and the
+// north at a pixel, using Horn's 3x3 method. Edge pixels reuse their nearest
+// neighbors.
+func (g *Grid) Gradient(x int, y int, metersPerPixel float64) (float64, float64) {
+	at := func(dx int, dy int) float64 {
+		return g.At(max(0, min(g.Width-1, x+dx)), max(0, min(g.Height-1, y+dy)))
+	}
+
+	a, b, c := at(-1, -1), at(0, -1), at(1, -1)
+	d, f := at(-1, 0), at(1, 0)
+	gg, h, i := at(-1, 1), at(0, 1), at(1, 1)
+
+	east := ((c + 2*f + i) - (a + 2*d + gg)) / (8 * metersPerPixel)
+	// image rows run from north to south
+	north := ((a + 2*b + c) - (gg + 2*h + i)) / (8 * metersPerPixel)
+	return east, north
+}
+
+// Slope returns the steepness of every pixel in degrees.
+func (g *Grid) Slope(metersPerPixel float64) *Grid {
+	slope := NewGrid(g.Width, g.Height)
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			east, north := g.Gradient(x, y, metersPerPixel)
+			slope.Set(x, y, math.Atan(math.Hypot(east, north))*180/math.Pi)
+		}
+	}
+	return slope
+}
+
+// Aspect returns the compass direction every pixel faces in degrees, 0 being
+// north and 90 east. Flat pixels are -1.
+func (g *Grid) Aspect(metersPerPixel float64) *Grid {
+	aspect := NewGrid(g.Width, g.Height)
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			east, north := g.Gradient(x, y, metersPerPixel)
+			if east == 0 && north == 0 {
+				aspect.Set(x, y, -1)
+				continue
+			}
+			// the slope faces downhill, against the gradient
+			degrees := math.Atan2(-east, -north) * 180 / math.Pi
+			if degrees < 0 {
+				degrees += 360
+			}
+			aspect.Set(x, y, degrees)
+		}
+	}
+	return aspect
+}
+
+// Hillshade lights the terrain from a sun at azimuth degrees clockwise from
+// north and altitude degrees above the horizon.
+func (g *Grid) Hillshade(metersPerPixel float64, azimuth float64, altitude float64) *image.Gray {
+	azimuthRad := azimuth * math.Pi / 180
+	altitudeRad := altitude * math.Pi / 180
+	lightEast := math.Sin(azimuthRad) * math.Cos(altitudeRad)
+	lightNorth := math.Cos(azimuthRad) * math.Cos(altitudeRad)
+	lightUp := math.Sin(altitudeRad)
+
+	img := image.NewGray(image.Rect(0, 0, g.Width, g.Height))
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			east, north := g.Gradient(x, y, metersPerPixel)
+			// dot product of the unit surface normal and the light direction
+			shade := (-east*lightEast - north*lightNorth + lightUp) / math.Sqrt(east*east+north*north+1)
+			img.Pix[img.PixOffset(x, y)] = uint8(math.Round(math.Max(0, shade) * 255))
+		}
+	}
+	return img
+}
+
+// NormalMap returns a tangent-space normal map with the OpenGL convention:
+// red points east, green north and blue up. strength exaggerates the slopes,
+// 1 being the true shape of the terrain.
+func (g *Grid) NormalMap(metersPerPixel float64, strength float64) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, g.Width, g.Height))
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			east, north := g.Gradient(x, y, metersPerPixel)
+			nx, ny, nz := -east*strength, -north*strength, 1.0
+			length := math.Sqrt(nx*nx + ny*ny + nz*nz)
+
+			offset := img.PixOffset(x, y)
+			img.Pix[offset] = uint8(math.Round((nx/length + 1) / 2 * 255))
+			img.Pix[offset+1] = uint8(math.Round((ny/length + 1) / 2 * 255))
+			img.Pix[offset+2] = uint8(math.Round((nz/length + 1) / 2 * 255))
+			img.Pix[offset+3] = 255
+		}
+	}
+	return img
+}
+
+// Gray returns the grid as an 8-bit grayscale image where black is min and
+// white is max.
+func (g *Grid) Gray(min float64, max float64) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, g.Width, g.Height))
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			value := 0.0
+			if max > min {
+				value = math.Max(0, math.Min(1, (g.At(x, y)-min)/(max-min)))
+			}
+			img.Pix[img.PixOffset(x, y)] = uint8(math.Round(value * 255))
+		}
+	}
+	return img
+}
+
+// Mean returns the average of all values in the grid.
+func (g *Grid) Mean() float64 {
+	sum := 0.0
+	for _, value := range g.Values {
+		sum += float64(value)
+	}
+	return sum / float64(len(g.Values))
+}
@@ -0,0 +1,258 @@
+package jobs
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusFailed  = "failed"
+	StatusDone    = "done"
+)
+
+const collectionName = "jobs"
+
+// Job is a single run of a queued job handed to its Handler.
+type Job struct {
+	Record *models.Record
+	queue  *Queue
+}
+
+func (j *Job) Type() string {
+	return j.Record.GetString("type")
+}
+
+// RecordId is the id of the record the job processes.
+func (j *Job) RecordId() string {
+	return j.Record.GetString("record")
+}
+
+func (j *Job) Attempt() int {
+	return j.Record.GetInt("attempts")
+}
+
+// LastAttempt reports whether the job will be marked as failed instead of
+// retried if this run returns an error.
+func (j *Job) LastAttempt() bool {
+	return j.Attempt() >= j.Record.GetInt("maxAttempts")
+}
+
+// Progress stores how far along the job is, in percent.
+func (j *Job) Progress(percent int) {
+	j.Record.Set("progress", percent)
+	if err := j.queue.app.Dao().SaveRecord(j.Record); err != nil {
+		log.Printf("Failed to save progress for job %s: %v", j.Record.Id, err)
+	}
+}
+
+type Handler func(job *Job) error
+
+// Queue runs jobs stored in the jobs collection on a pool of worker
+// goroutines. Jobs survive restarts; jobs left running by a crash are picked
+// up again when the queue starts. Jobs for the same record never run at the
+// same time, whatever their type, so handlers don't race on saving it.
+type Queue struct {
+	app          core.App
+	workers      int
+	maxAttempts  int
+	pollInterval time.Duration
+	retryDelay   time.Duration
+
+	handlers map[string]Handler
+	claimMu  sync.Mutex
+	wake     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func New(app core.App, workers int) *Queue {
+	return &Queue{
+		app:          app,
+		workers:      workers,
+		maxAttempts:  3,
+		pollInterval: 5 * time.Second,
+		retryDelay:   10 * time.Second,
+		handlers:     map[string]Handler{},
+		wake:         make(chan struct{}, workers),
+		stop:         make(chan struct{}),
+	}
+}
+
+// Handle registers the handler for jobs of the given type.
+func (q *Queue) Handle(jobType string, handler Handler) {
+	q.handlers[jobType] = handler
+}
+
+// Enqueue stores a new pending job for the record and wakes a worker.
+func (q *Queue) Enqueue(jobType string, recordId string) (*models.Record, error) {
+	if _, ok := q.handlers[jobType]; !ok {
+		return nil, fmt.Errorf("no handler for job type %q", jobType)
+	}
+
+	collection, err := q.app.Dao().FindCollectionByNameOrId(collectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	job := models.NewRecord(collection)
+	job.Set("type", jobType)
+	job.Set("record", recordId)
+	job.Set("status", StatusPending)
+	job.Set("attempts", 0)
+	job.Set("maxAttempts", q.maxAttempts)
+	job.Set("progress", 0)
+	job.Set("runAfter", types.NowDateTime())
+	if err := q.app.Dao().SaveRecord(job); err != nil {
+		return nil, err
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	return job, nil
+}
+
+// Start resets jobs interrupted by a previous shutdown and starts the workers.
+func (q *Queue) Start() error {
+	interrupted, err := q.app.Dao().FindRecordsByFilter(collectionName, "status = {:status}", "", 0, 0, dbx.Params{"status": StatusRunning})
+	if err != nil {
+		return err
+	}
+	for _, job := range interrupted {
+		job.Set("status", StatusPending)
+		if err := q.app.Dao().SaveRecord(job); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+
+	return nil
+}
+
+// Stop waits for running jobs to finish and stops the workers. Calling it
+// again does nothing.
+func (q *Queue) Stop() {
+	q.stopOnce.Do(func() {
+		close(q.stop)
+	})
+	q.wg.Wait()
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+
+	for {
+		job, err := q.claim()
+		if err != nil {
+			log.Printf("Failed to claim job: %v", err)
+		}
+		if job != nil {
+			q.run(job)
+			continue
+		}
+
+		select {
+		case <-q.stop:
+			return
+		case <-q.wake:
+		case <-time.After(q.pollInterval):
+		}
+	}
+}
+
+// claim marks the oldest due pending job as running and returns it, or nil if
+// there is nothing to do. Jobs whose record has a job running wait their turn.
+func (q *Queue) claim() (*Job, error) {
+	q.claimMu.Lock()
+	defer q.claimMu.Unlock()
+
+	record := &models.Record{}
+	err := q.app.Dao().RecordQuery(collectionName).
+		AndWhere(dbx.HashExp{"status": StatusPending}).
+		AndWhere(dbx.NewExp("[[runAfter]] <= {:now}", dbx.Params{"now": types.NowDateTime().String()})).
+		AndWhere(dbx.NewExp(
+			"[[record]] NOT IN (SELECT [[record]] FROM {{"+collectionName+"}} WHERE [[status]] = {:running})",
+			dbx.Params{"running": StatusRunning},
+		)).
+		OrderBy("created ASC").
+		Limit(1).
+		One(record)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	record.Set("status", StatusRunning)
+	record.Set("attempts", record.GetInt("attempts")+1)
+	if err := q.app.Dao().SaveRecord(record); err != nil {
+		return nil, err
+	}
+
+	return &Job{Record: record, queue: q}, nil
+}
+
+func (q *Queue) run(job *Job) {
+	handler, ok := q.handlers[job.Type()]
+
+	var err error
+	if !ok {
+		err = fmt.Errorf("no handler for job type %q", job.Type())
+	} else {
+		err = q.safeRun(handler, job)
+	}
+
+	record := job.Record
+	switch {
+	case err == nil:
+		record.Set("status", StatusDone)
+		record.Set("progress", 100)
+		record.Set("error", "")
+	case job.LastAttempt():
+		log.Printf("Job %s (%s) failed: %v", record.Id, job.Type(), err)
+		record.Set("status", StatusFailed)
+		record.Set("error", err.Error())
+	default:
+		log.Printf("Job %s (%s) failed, retrying: %v", record.Id, job.Type(), err)
+		delay := q.retryDelay << (job.Attempt() - 1)
+		runAfter, _ := types.ParseDateTime(time.Now().Add(delay))
+		record.Set("status", StatusPending)
+		record.Set("error", err.Error())
+		record.Set("runAfter", runAfter)
+	}
+
+	if err := q.app.Dao().SaveRecord(record); err != nil {
+		log.Printf("Failed to save job %s: %v", record.Id, err)
+	}
+}
+
+// safeRun turns a panicking handler into a failed attempt instead of taking
+// down the worker.
+func (q *Queue) safeRun(handler Handler, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	return handler(job)
+}
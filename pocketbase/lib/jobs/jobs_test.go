@@ -0,0 +1,231 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tests"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// jsonV2 is set when built with GOEXPERIMENT=jsonv2.
+var jsonV2 = false
+
+func newTestQueue(t *testing.T) (*Queue, *tests.TestApp) {
+	t.Helper()
+
+	if jsonV2 {
+		t.Skip("PocketBase v0.21 collections don't load with GOEXPERIMENT=jsonv2")
+	}
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(app.Cleanup)
+
+	collection := &models.Collection{
+		Name: collectionName,
+		Type: models.CollectionTypeBase,
+		Schema: schema.NewSchema(
+			&schema.SchemaField{Name: "type", Type: schema.FieldTypeText, Required: true},
+			&schema.SchemaField{Name: "record", Type: schema.FieldTypeText, Required: true},
+			&schema.SchemaField{Name: "status", Type: schema.FieldTypeSelect, Options: &schema.SelectOptions{
+				MaxSelect: 1,
+				Values:    []string{StatusPending, StatusRunning, StatusFailed, StatusDone},
+			}},
+			&schema.SchemaField{Name: "attempts", Type: schema.FieldTypeNumber, Options: &schema.NumberOptions{}},
+			&schema.SchemaField{Name: "maxAttempts", Type: schema.FieldTypeNumber, Options: &schema.NumberOptions{}},
+			&schema.SchemaField{Name: "progress", Type: schema.FieldTypeNumber, Options: &schema.NumberOptions{}},
+			&schema.SchemaField{Name: "error", Type: schema.FieldTypeText, Options: &schema.TextOptions{}},
+			&schema.SchemaField{Name: "runAfter", Type: schema.FieldTypeDate, Options: &schema.DateOptions{}},
+		),
+	}
+	if err := app.Dao().SaveCollection(collection); err != nil {
+		t.Fatal(err)
+	}
+
+	q := New(app, 1)
+	q.pollInterval = 10 * time.Millisecond
+	q.retryDelay = time.Minute
+	return q, app
+}
+
+func enqueue(t *testing.T, q *Queue, jobType string, recordId string) *models.Record {
+	t.Helper()
+
+	job, err := q.Enqueue(jobType, recordId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// created has millisecond precision, keep the order of the jobs unambiguous
+	time.Sleep(2 * time.Millisecond)
+	return job
+}
+
+func reload(t *testing.T, app *tests.TestApp, job *models.Record) *models.Record {
+	t.Helper()
+
+	record, err := app.Dao().FindRecordById(collectionName, job.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
+
+func TestClaimTakesOldestDueJob(t *testing.T) {
+	q, app := newTestQueue(t)
+	q.Handle("test", func(job *Job) error { return nil })
+
+	later := enqueue(t, q, "test", "a")
+	runAfter, _ := types.ParseDateTime(time.Now().Add(time.Hour))
+	later.Set("runAfter", runAfter)
+	if err := app.Dao().SaveRecord(later); err != nil {
+		t.Fatal(err)
+	}
+	first := enqueue(t, q, "test", "b")
+	enqueue(t, q, "test", "c")
+
+	job, err := q.claim()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job == nil || job.Record.Id != first.Id {
+		t.Fatalf("claimed %v, want the job for record b", job)
+	}
+	if got := job.Attempt(); got != 1 {
+		t.Errorf("attempts = %d, want 1", got)
+	}
+	if got := reload(t, app, first).GetString("status"); got != StatusRunning {
+		t.Errorf("status = %q, want %q", got, StatusRunning)
+	}
+}
+
+func TestClaimOneJobPerRecord(t *testing.T) {
+	q, _ := newTestQueue(t)
+	q.Handle("test", func(job *Job) error { return nil })
+
+	enqueue(t, q, "test", "a")
+	enqueue(t, q, "test", "a")
+	other := enqueue(t, q, "test", "b")
+
+	first, err := q.claim()
+	if err != nil || first == nil {
+		t.Fatalf("claim() = %v, %v, want a job", first, err)
+	}
+	second, err := q.claim()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == nil || second.Record.Id != other.Id {
+		t.Fatalf("claimed %v while record a had a running job, want the job for record b", second)
+	}
+	if job, err := q.claim(); err != nil || job != nil {
+		t.Fatalf("claim() = %v, %v, want nothing", job, err)
+	}
+
+	q.run(first)
+	if job, err := q.claim(); err != nil || job == nil || job.RecordId() != "a" {
+		t.Fatalf("claim() = %v, %v, want the second job for record a", job, err)
+	}
+}
+
+func TestRetryBacksOff(t *testing.T) {
+	q, app := newTestQueue(t)
+	q.Handle("test", func(job *Job) error { return errors.New("boom") })
+	enqueued := enqueue(t, q, "test", "a")
+
+	for attempt := 1; attempt < q.maxAttempts; attempt++ {
+		job, err := q.claim()
+		if err != nil || job == nil {
+			t.Fatalf("attempt %d: claim() = %v, %v", attempt, job, err)
+		}
+		before := time.Now()
+		q.run(job)
+
+		record := reload(t, app, enqueued)
+		if got := record.GetString("status"); got != StatusPending {
+			t.Errorf("attempt %d: status = %q, want %q", attempt, got, StatusPending)
+		}
+		if got := record.GetString("error"); got != "boom" {
+			t.Errorf("attempt %d: error = %q, want %q", attempt, got, "boom")
+		}
+		want := q.retryDelay << (attempt - 1)
+		delay := record.GetDateTime("runAfter").Time().Sub(before)
+		if delay < want-time.Second || delay > want+time.Second {
+			t.Errorf("attempt %d: retried after %v, want %v", attempt, delay, want)
+		}
+
+		// make the retry due now
+		record.Set("runAfter", types.NowDateTime())
+		if err := app.Dao().SaveRecord(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFailsAfterMaxAttempts(t *testing.T) {
+	q, app := newTestQueue(t)
+	q.Handle("test", func(job *Job) error { panic("boom") })
+	enqueued := enqueue(t, q, "test", "a")
+	enqueued.Set("attempts", q.maxAttempts-1)
+	if err := app.Dao().SaveRecord(enqueued); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := q.claim()
+	if err != nil || job == nil {
+		t.Fatalf("claim() = %v, %v", job, err)
+	}
+	if !job.LastAttempt() {
+		t.Errorf("attempt %d of %d is not the last", job.Attempt(), q.maxAttempts)
+	}
+	q.run(job)
+
+	record := reload(t, app, enqueued)
+	if got := record.GetString("status"); got != StatusFailed {
+		t.Errorf("status = %q, want %q", got, StatusFailed)
+	}
+	if got := record.GetString("error"); got != "job panicked: boom" {
+		t.Errorf("error = %q, want %q", got, "job panicked: boom")
+	}
+	if job, err := q.claim(); err != nil || job != nil {
+		t.Errorf("claim() = %v, %v, want nothing after failing", job, err)
+	}
+}
+
+func TestStartRecoversRunningJobs(t *testing.T) {
+	q, app := newTestQueue(t)
+	done := make(chan string, 1)
+	q.Handle("test", func(job *Job) error {
+		done <- job.RecordId()
+		return nil
+	})
+	enqueued := enqueue(t, q, "test", "a")
+	enqueued.Set("status", StatusRunning)
+	if err := app.Dao().SaveRecord(enqueued); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-done:
+		if got != "a" {
+			t.Errorf("ran the job for record %q, want a", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("interrupted job did not run again")
+	}
+
+	q.Stop()
+	q.Stop()
+
+	if got := reload(t, app, enqueued).GetString("status"); got != StatusDone {
+		t.Errorf("status = %q, want %q", got, StatusDone)
+	}
+}
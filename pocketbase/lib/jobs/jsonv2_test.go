@@ -0,0 +1,9 @@
+//go:build goexperiment.jsonv2
+
+package jobs
+
+func init() {
+	// schema.SchemaField.UnmarshalJSON in PocketBase v0.21 recurses forever
+	// when encoding/json is backed by json/v2, so no collection can be loaded
+	jsonV2 = true
+}
@@ -0,0 +1,166 @@
+package landscape
+
+// MajorityFilter replaces every pixel with the most common class in the
+// (2*radius+1)² window around it. Ties keep the pixel's own class.
+func (m *ClassMap) MajorityFilter(radius int) {
+	if radius <= 0 {
+		return
+	}
+
+	filtered := make([]int, len(m.Indices))
+	counts := map[int]int{}
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			clear(counts)
+			for wy := max(0, y-radius); wy <= min(m.Height-1, y+radius); wy++ {
+				for wx := max(0, x-radius); wx <= min(m.Width-1, x+radius); wx++ {
+					counts[m.At(wx, wy)]++
+				}
+			}
+
+			own := m.At(x, y)
+			best := own
+			for class, count := range counts {
+				if count > counts[best] || (count == counts[best] && best != own && class < best) {
+					best = class
+				}
+			}
+			filtered[y*m.Width+x] = best
+		}
+	}
+	m.Indices = filtered
+}
+
+// patchNeighbors counts, for every patch, the classified pixels of other
+// classes 8-adjacent to it, matching the connectivity of Patches, and whether
+// it touches the border of the map.
+func (m *ClassMap) patchNeighbors(labels []int, patches int) ([]map[int]int, []bool) {
+	neighbors := make([]map[int]int, patches)
+	for i := range neighbors {
+		neighbors[i] = map[int]int{}
+	}
+	border := make([]bool, patches)
+
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			patch := labels[y*m.Width+x]
+			if patch < 0 {
+				continue
+			}
+			if x == 0 || y == 0 || x == m.Width-1 || y == m.Height-1 {
+				border[patch] = true
+			}
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx < 0 || ny < 0 || nx >= m.Width || ny >= m.Height {
+						continue
+					}
+					if class := m.At(nx, ny); class >= 0 && labels[ny*m.Width+nx] != patch {
+						neighbors[patch][class]++
+					}
+				}
+			}
+		}
+	}
+	return neighbors, border
+}
+
+// mergePatches gives every patch picked by merge the class it returns and
+// reports whether anything changed.
+func (m *ClassMap) mergePatches(merge func(size int, neighbors map[int]int, border bool) (int, bool)) bool {
+	labels, classes := m.Patches()
+	sizes := make([]int, len(classes))
+	for _, patch := range labels {
+		if patch >= 0 {
+			sizes[patch]++
+		}
+	}
+	neighbors, border := m.patchNeighbors(labels, len(classes))
+
+	targets := make([]int, len(classes))
+	changed := false
+	for patch := range classes {
+		targets[patch] = classes[patch]
+		if class, ok := merge(sizes[patch], neighbors[patch], border[patch]); ok {
+			targets[patch] = class
+			changed = true
+		}
+	}
+	if !changed {
+		return false
+	}
+
+	for i, patch := range labels {
+		if patch >= 0 {
+			m.Indices[i] = targets[patch]
+		}
+	}
+	return true
+}
+
+// RemoveSmallPatches merges patches smaller than minPixels into the
+// neighboring class they share the longest border with. This enforces a
+// minimum mapping unit.
+func (m *ClassMap) RemoveSmallPatches(minPixels int) {
+	if minPixels <= 1 {
+		return
+	}
+
+	// merging can leave new small patches next to each other, so repeat a
+	// few times
+	for pass := 0; pass < 4; pass++ {
+		changed := m.mergePatches(func(size int, neighbors map[int]int, border bool) (int, bool) {
+			if size >= minPixels || len(neighbors) == 0 {
+				return 0, false
+			}
+			best, bestCount := 0, 0
+			for class, count := range neighbors {
+				if count > bestCount || (count == bestCount && class < best) {
+					best, bestCount = class, count
+				}
+			}
+			return best, true
+		})
+		if !changed {
+			return
+		}
+	}
+}
+
+// FillHoles fills patches of at most maxPixels that are enclosed by a single
+// other class with that class. Patches touching the border of the map are
+// never holes.
+func (m *ClassMap) FillHoles(maxPixels int) {
+	if maxPixels <= 0 {
+		return
+	}
+
+	m.mergePatches(func(size int, neighbors map[int]int, border bool) (int, bool) {
+		if border || size > maxPixels || len(neighbors) != 1 {
+			return 0, false
+		}
+		for class := range neighbors {
+			return class, true
+		}
+		return 0, false
+	})
+}
+
+// Cleanup holds the parameters of the morphological cleanup of a class map.
+type Cleanup struct {
+	// MajorityRadius is the radius of the majority filter window in pixels.
+	MajorityRadius int
+	// MinPatchPixels is the minimum mapping unit in pixels.
+	MinPatchPixels int
+	// MaxHolePixels is the size of the largest hole that gets filled.
+	MaxHolePixels int
+}
+
+// Apply runs the majority filter, the minimum mapping unit and the hole
+// filling in that order, skipping the ones that are off.
+func (c Cleanup) Apply(m *ClassMap) {
+	m.MajorityFilter(c.MajorityRadius)
+	m.RemoveSmallPatches(c.MinPatchPixels)
+	m.FillHoles(c.MaxHolePixels)
+}
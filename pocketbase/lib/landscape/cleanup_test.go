@@ -0,0 +1,131 @@
+package landscape
+
+import (
+	"reflect"
+	"testing"
+)
+
+func assertMap(t *testing.T, name string, got *ClassMap, want *ClassMap) {
+	t.Helper()
+	if !reflect.DeepEqual(got.Indices, want.Indices) {
+		t.Errorf("%s:\n got %v\nwant %v", name, got.Indices, want.Indices)
+	}
+}
+
+func TestMajorityFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		m      *ClassMap
+		radius int
+		want   *ClassMap
+	}{
+		{"removes speckle", classMap("00000", "00100", "00020", "00000"), 1, classMap("00000", "00000", "00000", "00000")},
+		{"keeps the pixel's own class on ties", classMap("01"), 1, classMap("01")},
+		{"keeps solid areas", classMap("000111", "000111", "000111"), 1, classMap("000111", "000111", "000111")},
+		{"radius 0 is off", classMap("010"), 0, classMap("010")},
+		{"wider windows remove larger speckle", classMap("00000", "01100", "01100", "00000", "00000"), 2, classMap("00000", "00000", "00000", "00000", "00000")},
+	}
+	for _, test := range tests {
+		test.m.MajorityFilter(test.radius)
+		assertMap(t, test.name, test.m, test.want)
+	}
+}
+
+func TestRemoveSmallPatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		m         *ClassMap
+		minPixels int
+		want      *ClassMap
+	}{
+		{
+			"merges into the surrounding class",
+			classMap("00011", "02011", "00011"), 2,
+			classMap("00011", "00011", "00011"),
+		},
+		{
+			// 5 neighbors of class 0, 3 of class 1
+			"merges into the dominant neighbor",
+			classMap("0001", "0021", "0001"), 2,
+			classMap("0001", "0001", "0001"),
+		},
+		{
+			"keeps patches of minPixels",
+			classMap("00011", "02211", "00011"), 2,
+			classMap("00011", "02211", "00011"),
+		},
+		{
+			// patches are 8-connected, so neighbors are too
+			"merges patches touching others only diagonally",
+			classMap("11..", "11..", "..0.", "...."), 2,
+			classMap("11..", "11..", "..1.", "...."),
+		},
+		{
+			"leaves patches without neighbors",
+			classMap("..", ".0"), 2,
+			classMap("..", ".0"),
+		},
+	}
+	for _, test := range tests {
+		test.m.RemoveSmallPatches(test.minPixels)
+		assertMap(t, test.name, test.m, test.want)
+	}
+}
+
+func TestFillHoles(t *testing.T) {
+	tests := []struct {
+		name      string
+		m         *ClassMap
+		maxPixels int
+		want      *ClassMap
+	}{
+		{
+			"fills an enclosed hole",
+			classMap("11111", "10011", "11111"), 2,
+			classMap("11111", "11111", "11111"),
+		},
+		{
+			"leaves holes larger than maxPixels",
+			classMap("11111", "10001", "11111"), 2,
+			classMap("11111", "10001", "11111"),
+		},
+		{
+			"leaves patches touching the border",
+			classMap("0111", "1111", "1111"), 2,
+			classMap("0111", "1111", "1111"),
+		},
+		{
+			// the hole is 8-connected to the 2 in the corner
+			"leaves holes touching a second class diagonally",
+			classMap("2111", "1011", "1111"), 2,
+			classMap("2111", "1011", "1111"),
+		},
+	}
+	for _, test := range tests {
+		test.m.FillHoles(test.maxPixels)
+		assertMap(t, test.name, test.m, test.want)
+	}
+}
+
+func TestCleanupApply(t *testing.T) {
+	m := classMap(
+		"0000000",
+		"0200000",
+		"0000111",
+		"0001111",
+		"0001111",
+	)
+	Cleanup{MinPatchPixels: 2, MaxHolePixels: 4}.Apply(m)
+	assertMap(t, "minimum mapping unit", m, classMap(
+		"0000000",
+		"0000000",
+		"0000111",
+		"0001111",
+		"0001111",
+	))
+
+	// everything off changes nothing
+	m = classMap("010", "101")
+	Cleanup{}.Apply(m)
+	assertMap(t, "no cleanup", m, classMap("010", "101"))
+}
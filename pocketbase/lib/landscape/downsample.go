@@ -0,0 +1,92 @@
+package landscape
+
+// Rule lets a class win a downsampled cell without being the most common.
+// Among the classes covering at least MinCoverage of a cell, the one with
+// the highest Priority wins. Priority 0 never overrides the mode.
+type Rule struct {
+	Priority    int     `json:"priority"`
+	MinCoverage float64 `json:"minCoverage"`
+}
+
+// Coverage is the fraction of every cell of a downsampled map covered by
+// every class. Fractions holds Classes values per cell, cells row by row
+// from the north-west corner.
+type Coverage struct {
+	Width     int       `json:"width"`
+	Height    int       `json:"height"`
+	Classes   int       `json:"classes"`
+	Fractions []float32 `json:"fractions"`
+}
+
+func (c *Coverage) At(x int, y int, class int) float32 {
+	return c.Fractions[(y*c.Width+x)*c.Classes+class]
+}
+
+// Downsample reduces the map to width x height cells. Each cell takes the
+// most common class among the pixels it covers unless a rule picks another
+// one, and the coverage of every class in every cell is returned alongside.
+func (m *ClassMap) Downsample(width int, height int, classes int, rules []Rule) (*ClassMap, *Coverage) {
+	downsampled := &ClassMap{Width: width, Height: height, Indices: make([]int, width*height)}
+	coverage := &Coverage{Width: width, Height: height, Classes: classes, Fractions: make([]float32, width*height*classes)}
+
+	rule := func(class int) Rule {
+		if class < len(rules) {
+			return rules[class]
+		}
+		return Rule{}
+	}
+
+	// pixel ranges of the cells, at least one pixel wide when upsampling
+	span := func(cell int, cells int, pixels int) (int, int) {
+		start := cell * pixels / cells
+		end := (cell + 1) * pixels / cells
+		return start, max(end, start+1)
+	}
+
+	counts := make([]int, classes)
+	for cy := 0; cy < height; cy++ {
+		y0, y1 := span(cy, height, m.Height)
+		for cx := 0; cx < width; cx++ {
+			x0, x1 := span(cx, width, m.Width)
+
+			clear(counts)
+			total := 0
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					if class := m.At(x, y); class >= 0 && class < classes {
+						counts[class]++
+						total++
+					}
+				}
+			}
+
+			cell := cy*width + cx
+			if total == 0 {
+				downsampled.Indices[cell] = -1
+				continue
+			}
+
+			mode, winner := 0, -1
+			for class, count := range counts {
+				fraction := float64(count) / float64(total)
+				coverage.Fractions[cell*classes+class] = float32(fraction)
+
+				if count > counts[mode] {
+					mode = class
+				}
+				if r := rule(class); r.Priority > 0 && count > 0 && fraction >= r.MinCoverage {
+					if winner < 0 || r.Priority > rule(winner).Priority {
+						winner = class
+					}
+				}
+			}
+
+			if winner < 0 {
+				winner = mode
+			}
+			downsampled.Indices[cell] = winner
+		}
+	}
+
+	return downsampled, coverage
+}
@@ -0,0 +1,42 @@
+package landscape
+
+import "testing"
+
+// a 2x2 block, class 1 covering exactly a quarter of it
+func quarter() *ClassMap {
+	return &ClassMap{Width: 2, Height: 2, Indices: []int{0, 0, 0, 1}}
+}
+
+func TestDownsampleRuleAppliesAtMinCoverage(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+		want  int
+	}{
+		{"no rules takes the mode", nil, 0},
+		{"exactly MinCoverage wins", []Rule{{}, {Priority: 1, MinCoverage: 0.25}}, 1},
+		{"just short of MinCoverage loses", []Rule{{}, {Priority: 1, MinCoverage: 0.2501}}, 0},
+		{"priority 0 never overrides", []Rule{{}, {MinCoverage: 0.1}}, 0},
+		{"the higher priority wins", []Rule{{Priority: 2, MinCoverage: 0.5}, {Priority: 1, MinCoverage: 0.25}}, 0},
+	}
+	for _, test := range tests {
+		downsampled, coverage := quarter().Downsample(1, 1, 2, test.rules)
+		if got := downsampled.At(0, 0); got != test.want {
+			t.Errorf("%s: cell is class %d, want %d", test.name, got, test.want)
+		}
+		if got := coverage.At(0, 0, 1); got != 0.25 {
+			t.Errorf("%s: class 1 covers %v, want 0.25", test.name, got)
+		}
+	}
+}
+
+func TestDownsampleLeavesEmptyCellsUnclassified(t *testing.T) {
+	m := &ClassMap{Width: 2, Height: 1, Indices: []int{-1, 0}}
+	downsampled, _ := m.Downsample(2, 1, 1, nil)
+	if got := downsampled.At(0, 0); got != -1 {
+		t.Errorf("empty cell is class %d, want -1", got)
+	}
+	if got := downsampled.At(1, 0); got != 0 {
+		t.Errorf("cell is class %d, want 0", got)
+	}
+}
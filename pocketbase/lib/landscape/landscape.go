@@ -0,0 +1,245 @@
+package landscape
+
+import (
+	"math"
+	"sort"
+)
+
+// ClassMap is a raster of class indices from the north-west corner. Negative
+// indices are unclassified.
+type ClassMap struct {
+	Width   int
+	Height  int
+	Indices []int
+}
+
+func (m *ClassMap) At(x int, y int) int {
+	return m.Indices[y*m.Width+x]
+}
+
+// Patches labels the 8-connected patches of equal class. It returns the
+// patch of every pixel (-1 for unclassified) and the class of every patch.
+func (m *ClassMap) Patches() ([]int, []int) {
+	labels := make([]int, len(m.Indices))
+	for i := range labels {
+		labels[i] = -1
+	}
+
+	var classes []int
+	stack := []int{}
+	for start, class := range m.Indices {
+		if class < 0 || labels[start] >= 0 {
+			continue
+		}
+
+		patch := len(classes)
+		classes = append(classes, class)
+		labels[start] = patch
+		stack = append(stack[:0], start)
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := i%m.Width, i/m.Width
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx < 0 || ny < 0 || nx >= m.Width || ny >= m.Height {
+						continue
+					}
+					n := ny*m.Width + nx
+					if labels[n] < 0 && m.Indices[n] == class {
+						labels[n] = patch
+						stack = append(stack, n)
+					}
+				}
+			}
+		}
+	}
+
+	return labels, classes
+}
+
+// PatchSizes summarizes the areas of a class's patches. Bins counts patches
+// by powers of two: bin i holds patches of 2^i up to 2^(i+1) pixels.
+type PatchSizes struct {
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	StdDev float64 `json:"stdDev"`
+	Bins   []int   `json:"bins"`
+}
+
+type ClassMetrics struct {
+	Name              string     `json:"name"`
+	ClassId           int        `json:"classId"`
+	Area              float64    `json:"area"`
+	Percentage        float64    `json:"percentage"`
+	Patches           int        `json:"patches"`
+	PatchSizes        PatchSizes `json:"patchSizes"`
+	LargestPatchIndex float64    `json:"largestPatchIndex"`
+	EdgeLength        float64    `json:"edgeLength"`
+	EdgeDensity       float64    `json:"edgeDensity"`
+}
+
+// Metrics are landscape metrics in the spirit of FRAGSTATS. Areas are in m²
+// and lengths in m when the ground resolution is known, otherwise in pixels
+// (Unit tells which). Edge density is edge length per hectare, or per 10000
+// pixels. The border of the map doesn't count as edge.
+type Metrics struct {
+	Unit              string         `json:"unit"`
+	MetersPerPixel    float64        `json:"metersPerPixel"`
+	Width             int            `json:"width"`
+	Height            int            `json:"height"`
+	Area              float64        `json:"area"`
+	Patches           int            `json:"patches"`
+	LargestPatchIndex float64        `json:"largestPatchIndex"`
+	EdgeLength        float64        `json:"edgeLength"`
+	EdgeDensity       float64        `json:"edgeDensity"`
+	ShorelineLength   float64        `json:"shorelineLength"`
+	Classes           []ClassMetrics `json:"classes"`
+}
+
+// Class names a class index of the map.
+type Class struct {
+	Name    string
+	ClassId int
+}
+
+// Compute measures the map. water is the index of the class whose edges count
+// as shoreline, -1 for none.
+func Compute(m *ClassMap, classes []Class, metersPerPixel float64, water int) Metrics {
+	unit, length := "m", metersPerPixel
+	if metersPerPixel <= 0 {
+		unit, length, metersPerPixel = "px", 1, 0
+	}
+	area := length * length
+
+	metrics := Metrics{
+		Unit:           unit,
+		MetersPerPixel: metersPerPixel,
+		Width:          m.Width,
+		Height:         m.Height,
+		Area:           float64(m.Width*m.Height) * area,
+		Classes:        make([]ClassMetrics, len(classes)),
+	}
+	for i, class := range classes {
+		metrics.Classes[i].Name = class.Name
+		metrics.Classes[i].ClassId = class.ClassId
+	}
+	inRange := func(class int) bool {
+		return class >= 0 && class < len(classes)
+	}
+
+	// edges between 4-neighbors of different classes
+	edges := make([]int, len(classes))
+	total, shoreline := 0, 0
+	countEdge := func(a int, b int) {
+		if a == b {
+			return
+		}
+		total++
+		if inRange(a) {
+			edges[a]++
+		}
+		if inRange(b) {
+			edges[b]++
+		}
+		if water >= 0 && (a == water) != (b == water) {
+			shoreline++
+		}
+	}
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			if x+1 < m.Width {
+				countEdge(m.At(x, y), m.At(x+1, y))
+			}
+			if y+1 < m.Height {
+				countEdge(m.At(x, y), m.At(x, y+1))
+			}
+		}
+	}
+
+	perHectare := func(edge float64) float64 {
+		if metrics.Area == 0 {
+			return 0
+		}
+		return edge / metrics.Area * 10000
+	}
+	metrics.EdgeLength = float64(total) * length
+	metrics.EdgeDensity = perHectare(metrics.EdgeLength)
+	metrics.ShorelineLength = float64(shoreline) * length
+
+	labels, patchClasses := m.Patches()
+	sizes := make([]int, len(patchClasses))
+	for _, patch := range labels {
+		if patch >= 0 {
+			sizes[patch]++
+		}
+	}
+
+	patchSizes := make([][]float64, len(classes))
+	largest := 0.0
+	for patch, class := range patchClasses {
+		if !inRange(class) {
+			continue
+		}
+		size := float64(sizes[patch]) * area
+		patchSizes[class] = append(patchSizes[class], size)
+		largest = math.Max(largest, size)
+	}
+	metrics.Patches = len(patchClasses)
+	if metrics.Area > 0 {
+		metrics.LargestPatchIndex = largest / metrics.Area * 100
+	}
+
+	for i := range metrics.Classes {
+		class := &metrics.Classes[i]
+		sizes := patchSizes[i]
+		class.Patches = len(sizes)
+		class.EdgeLength = float64(edges[i]) * length
+		class.EdgeDensity = perHectare(class.EdgeLength)
+		if len(sizes) == 0 {
+			continue
+		}
+
+		for _, size := range sizes {
+			class.Area += size
+		}
+		class.Percentage = class.Area / metrics.Area * 100
+		class.PatchSizes = summarize(sizes, area)
+		class.LargestPatchIndex = class.PatchSizes.Max / metrics.Area * 100
+	}
+
+	return metrics
+}
+
+func summarize(sizes []float64, pixelArea float64) PatchSizes {
+	sort.Float64s(sizes)
+	summary := PatchSizes{Min: sizes[0], Max: sizes[len(sizes)-1]}
+
+	sum := 0.0
+	for _, size := range sizes {
+		sum += size
+		bin := int(math.Log2(math.Round(size / pixelArea)))
+		for len(summary.Bins) <= bin {
+			summary.Bins = append(summary.Bins, 0)
+		}
+		summary.Bins[bin]++
+	}
+	summary.Mean = sum / float64(len(sizes))
+
+	if len(sizes)%2 == 1 {
+		summary.Median = sizes[len(sizes)/2]
+	} else {
+		summary.Median = (sizes[len(sizes)/2-1] + sizes[len(sizes)/2]) / 2
+	}
+
+	variance := 0.0
+	for _, size := range sizes {
+		variance += (size - summary.Mean) * (size - summary.Mean)
+	}
+	summary.StdDev = math.Sqrt(variance / float64(len(sizes)))
+
+	return summary
+}
@@ -0,0 +1,165 @@
+package landscape
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// classMap builds a map from rows of digits, '.' being unclassified.
+func classMap(rows ...string) *ClassMap {
+	m := &ClassMap{Width: len(rows[0]), Height: len(rows)}
+	for _, row := range rows {
+		for _, c := range row {
+			if c == '.' {
+				m.Indices = append(m.Indices, -1)
+			} else {
+				m.Indices = append(m.Indices, int(c-'0'))
+			}
+		}
+	}
+	return m
+}
+
+func near(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-9*math.Max(1, math.Abs(b))
+}
+
+func TestPatchesAreEightConnected(t *testing.T) {
+	tests := []struct {
+		name    string
+		m       *ClassMap
+		patches int
+	}{
+		{"diagonal checkerboard", classMap("10", "01"), 2},
+		{"diagonal line", classMap("100", "010", "001"), 2},
+		{"separated by a column", classMap("101", "101"), 3},
+		{"unclassified pixels aren't patches", classMap("1.1", "...", "1.1"), 4},
+		{"ring around a hole", classMap("111", "101", "111"), 2},
+	}
+	for _, test := range tests {
+		labels, classes := test.m.Patches()
+		if len(classes) != test.patches {
+			t.Errorf("%s: %d patches, want %d", test.name, len(classes), test.patches)
+		}
+		for i, label := range labels {
+			if class := test.m.Indices[i]; class < 0 && label != -1 || class >= 0 && classes[label] != class {
+				t.Errorf("%s: pixel %d of class %d is in patch %d", test.name, i, class, label)
+			}
+		}
+	}
+}
+
+func TestComputeEdgesAndShoreline(t *testing.T) {
+	// 4 pixel edges between land (0) and water (1)
+	m := classMap(
+		"0011",
+		"0011",
+		"0000",
+	)
+	classes := []Class{{Name: "land", ClassId: 10}, {Name: "water", ClassId: 80}}
+
+	tests := []struct {
+		metersPerPixel float64
+		unit           string
+		edge           float64
+		density        float64
+		area           float64
+	}{
+		// 1200 m², 40 m of edge
+		{10, "m", 40, 40.0 / 1200 * 10000, 1200},
+		{0, "px", 4, 4.0 / 12 * 10000, 12},
+	}
+	for _, test := range tests {
+		metrics := Compute(m, classes, test.metersPerPixel, 1)
+		if metrics.Unit != test.unit || metrics.Width != 4 || metrics.Height != 3 {
+			t.Errorf("unit %s, %dx%d", metrics.Unit, metrics.Width, metrics.Height)
+		}
+		if !near(metrics.Area, test.area) {
+			t.Errorf("area %v, want %v", metrics.Area, test.area)
+		}
+		if !near(metrics.EdgeLength, test.edge) || !near(metrics.ShorelineLength, test.edge) {
+			t.Errorf("edge %v and shoreline %v, want %v", metrics.EdgeLength, metrics.ShorelineLength, test.edge)
+		}
+		if !near(metrics.EdgeDensity, test.density) {
+			t.Errorf("edge density %v, want %v", metrics.EdgeDensity, test.density)
+		}
+		if metrics.Patches != 2 || !near(metrics.LargestPatchIndex, 800.0/1200*100) {
+			t.Errorf("%d patches, largest patch index %v", metrics.Patches, metrics.LargestPatchIndex)
+		}
+
+		for i, want := range []struct {
+			area       float64
+			percentage float64
+		}{{8 * test.area / 12, 800.0 / 12}, {4 * test.area / 12, 400.0 / 12}} {
+			class := metrics.Classes[i]
+			if class.Name != classes[i].Name || class.ClassId != classes[i].ClassId {
+				t.Errorf("class %d is %s (%d)", i, class.Name, class.ClassId)
+			}
+			if class.Patches != 1 || !near(class.Area, want.area) || !near(class.Percentage, want.percentage) {
+				t.Errorf("%s: %d patches, area %v (%v%%), want 1, %v (%v%%)", class.Name, class.Patches, class.Area, class.Percentage, want.area, want.percentage)
+			}
+			if !near(class.LargestPatchIndex, want.percentage) {
+				t.Errorf("%s: largest patch index %v, want %v", class.Name, class.LargestPatchIndex, want.percentage)
+			}
+			if !near(class.EdgeLength, test.edge) || !near(class.EdgeDensity, test.density) {
+				t.Errorf("%s: edge %v, density %v", class.Name, class.EdgeLength, class.EdgeDensity)
+			}
+		}
+	}
+}
+
+func TestComputeShorelineSkipsOtherEdges(t *testing.T) {
+	// land/forest edges don't count, the water column touches 3 land pixels
+	m := classMap(
+		"012",
+		"012",
+		"002",
+	)
+	metrics := Compute(m, []Class{{Name: "land"}, {Name: "forest"}, {Name: "water"}}, 5, 2)
+	if !near(metrics.EdgeLength, 6*5) {
+		t.Errorf("edge %v, want 30", metrics.EdgeLength)
+	}
+	if !near(metrics.ShorelineLength, 3*5) {
+		t.Errorf("shoreline %v, want 15", metrics.ShorelineLength)
+	}
+
+	if without := Compute(m, []Class{{Name: "land"}, {Name: "forest"}, {Name: "water"}}, 5, -1); without.ShorelineLength != 0 {
+		t.Errorf("shoreline %v without a water class", without.ShorelineLength)
+	}
+}
+
+func TestComputePatchSizeDistribution(t *testing.T) {
+	// class 0 has patches of 1, 1, 2 and 2 pixels, class 1 one of 9
+	m := classMap(
+		"01110",
+		"11111",
+		"00100",
+	)
+	metrics := Compute(m, []Class{{Name: "a"}, {Name: "b"}}, 2, -1)
+
+	want := PatchSizes{Min: 4, Max: 8, Mean: 6, Median: 6, StdDev: 2, Bins: []int{2, 2}}
+	if got := metrics.Classes[0].PatchSizes; !reflect.DeepEqual(got, want) {
+		t.Errorf("class a: %+v, want %+v", got, want)
+	}
+	if got := metrics.Classes[0].Patches; got != 4 {
+		t.Errorf("class a: %d patches, want 4", got)
+	}
+
+	// 9 pixels fall in the 8..15 bin
+	want = PatchSizes{Min: 36, Max: 36, Mean: 36, Median: 36, StdDev: 0, Bins: []int{0, 0, 0, 1}}
+	if got := metrics.Classes[1].PatchSizes; !reflect.DeepEqual(got, want) {
+		t.Errorf("class b: %+v, want %+v", got, want)
+	}
+	if metrics.Patches != 5 || !near(metrics.LargestPatchIndex, 9.0/15*100) {
+		t.Errorf("%d patches, largest patch index %v", metrics.Patches, metrics.LargestPatchIndex)
+	}
+}
+
+func TestComputeClassWithoutPixels(t *testing.T) {
+	metrics := Compute(classMap("00", "00"), []Class{{Name: "a"}, {Name: "b"}}, 1, -1)
+	empty := metrics.Classes[1]
+	if empty.Patches != 0 || empty.Area != 0 || empty.PatchSizes.Bins != nil || metrics.EdgeLength != 0 {
+		t.Errorf("empty class %+v, edge %v", empty, metrics.EdgeLength)
+	}
+}
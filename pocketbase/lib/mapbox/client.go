@@ -0,0 +1,112 @@
+package mapbox
+
+import (
+	utils "app/lib/utils"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Client is an HTTP client shared by all tile downloads. It limits the global
+// request rate and retries rate limited and failed requests with exponential
+// backoff.
+type Client struct {
+	HTTP       *http.Client
+	Limiter    *rate.Limiter
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// DefaultClient is configured from the environment:
+//
+//	TILE_HTTP_TIMEOUT   per request timeout, e.g. "30s"
+//	TILE_RATE_LIMIT     requests per second across all downloads
+//	TILE_RATE_BURST     requests allowed in a burst
+//	TILE_MAX_RETRIES    retries after the first attempt
+var DefaultClient = NewClientFromEnv()
+
+func NewClientFromEnv() *Client {
+	return &Client{
+		HTTP: &http.Client{
+			Timeout: utils.DurationFromEnv("TILE_HTTP_TIMEOUT", 30*time.Second),
+		},
+		Limiter:    rate.NewLimiter(rate.Limit(utils.FloatFromEnv("TILE_RATE_LIMIT", 10)), utils.IntFromEnv("TILE_RATE_BURST", 10)),
+		MaxRetries: utils.IntFromEnv("TILE_MAX_RETRIES", 4),
+		BaseDelay:  500 * time.Millisecond,
+		MaxDelay:   30 * time.Second,
+	}
+}
+
+// Do sends the request, waiting for the rate limiter before every attempt.
+// Responses with status 429 or 5xx and transport errors are retried; the last
+// response or error is returned once retries are exhausted.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := c.Limiter.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+
+		resp, err := c.HTTP.Do(req)
+		if attempt >= c.MaxRetries || !shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		delay := c.backoff(attempt)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				delay = retryAfter
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if delay > c.MaxDelay {
+			delay = c.MaxDelay
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.BaseDelay << attempt
+	if delay <= 0 || delay > c.MaxDelay {
+		delay = c.MaxDelay
+	}
+	// full jitter so a batch of tiles doesn't retry in lockstep
+	return time.Duration(rand.Int63n(int64(delay)) + 1)
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an
+// HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
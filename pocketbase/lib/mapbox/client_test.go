@@ -0,0 +1,205 @@
+package mapbox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// standIn serves the given statuses in order, repeating the last one, and
+// counts the requests it got.
+func standIn(t *testing.T, header http.Header, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	requests := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1)) - 1
+		status := statuses[min(n, len(statuses)-1)]
+		if status != http.StatusOK {
+			for key, values := range header {
+				w.Header()[key] = values
+			}
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func testClient(maxRetries int, baseDelay time.Duration, maxDelay time.Duration) *Client {
+	return &Client{
+		HTTP:       &http.Client{Timeout: 5 * time.Second},
+		Limiter:    rate.NewLimiter(rate.Inf, 1),
+		MaxRetries: maxRetries,
+		BaseDelay:  baseDelay,
+		MaxDelay:   maxDelay,
+	}
+}
+
+func get(t *testing.T, ctx context.Context, client *Client, url string) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if resp != nil {
+		t.Cleanup(func() { resp.Body.Close() })
+	}
+	return resp, err
+}
+
+func TestDoHonorsRetryAfter(t *testing.T) {
+	server, requests := standIn(t, http.Header{"Retry-After": {"0"}}, http.StatusTooManyRequests, http.StatusOK)
+
+	// the backoff alone would wait an hour, so finishing means Retry-After won
+	client := testClient(3, time.Hour, time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := get(t, ctx, client, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+}
+
+func TestDoBacksOffOnServerErrors(t *testing.T) {
+	server, requests := standIn(t, nil, http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK)
+	client := testClient(3, time.Millisecond, 5*time.Millisecond)
+
+	resp, err := get(t, context.Background(), client, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+}
+
+func TestDoReturnsLastResponseAfterMaxRetries(t *testing.T) {
+	server, requests := standIn(t, nil, http.StatusInternalServerError)
+	client := testClient(2, time.Millisecond, 5*time.Millisecond)
+
+	resp, err := get(t, context.Background(), client, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", resp.StatusCode)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+}
+
+func TestDoDoesNotRetryClientErrors(t *testing.T) {
+	server, requests := standIn(t, nil, http.StatusNotFound)
+	client := testClient(3, time.Millisecond, 5*time.Millisecond)
+
+	resp, err := get(t, context.Background(), client, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", resp.StatusCode)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
+
+func TestDoWaitsForRateLimiter(t *testing.T) {
+	server, requests := standIn(t, nil, http.StatusOK)
+	client := testClient(0, time.Millisecond, time.Millisecond)
+	client.Limiter = rate.NewLimiter(rate.Every(50*time.Millisecond), 1)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := get(t, context.Background(), client, server.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the first request uses the burst, the other two wait 50ms each, which
+	// the limiter schedules exactly, so 2x50ms is a strict lower bound
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("3 requests took %v, want at least 100ms", elapsed)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+}
+
+func TestDoStopsWaitingForRateLimiterWhenCancelled(t *testing.T) {
+	server, requests := standIn(t, nil, http.StatusOK)
+	client := testClient(0, time.Millisecond, time.Millisecond)
+	client.Limiter = rate.NewLimiter(rate.Every(time.Hour), 1)
+	client.Limiter.Allow()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := get(t, ctx, client, server.URL); err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := requests.Load(); got != 0 {
+		t.Errorf("requests = %d, want 0", got)
+	}
+}
+
+func TestDoStopsBackingOffWhenCancelled(t *testing.T) {
+	server, requests := standIn(t, nil, http.StatusServiceUnavailable)
+	client := testClient(5, time.Hour, time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := get(t, ctx, client, server.URL)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Do returned after %v", elapsed)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	future := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	past := time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)
+
+	tests := []struct {
+		value string
+		min   time.Duration
+		max   time.Duration
+		ok    bool
+	}{
+		{"", 0, 0, false},
+		{"garbage", 0, 0, false},
+		{"-1", 0, 0, false},
+		{"0", 0, 0, true},
+		{"7", 7 * time.Second, 7 * time.Second, true},
+		{future, 58 * time.Second, time.Minute, true},
+		{past, 0, 0, true},
+	}
+	for _, test := range tests {
+		delay, ok := parseRetryAfter(test.value)
+		if ok != test.ok || delay < test.min || delay > test.max {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v..%v, %v", test.value, delay, ok, test.min, test.max, test.ok)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package mapbox
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	ErrUnauthorized = errors.New("tile provider rejected the access token")
+	ErrRateLimited  = errors.New("tile provider rate limit exceeded")
+	ErrNotFound     = errors.New("tile not found")
+)
+
+// StatusError is returned when a tile server responds with a non-200 status.
+// It unwraps to ErrUnauthorized, ErrRateLimited or ErrNotFound where the
+// status code maps onto one of them.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	if reason := e.Unwrap(); reason != nil {
+		return fmt.Sprintf("%v: tile request to %s failed with HTTP status %d", reason, e.URL, e.StatusCode)
+	}
+	return fmt.Sprintf("tile request to %s failed with HTTP status %d", e.URL, e.StatusCode)
+}
+
+func (e *StatusError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusNotFound:
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DecodeError is returned when a tile was downloaded but isn't a readable
+// image.
+type DecodeError struct {
+	URL string
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("failed to decode tile from %s: %v", e.URL, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
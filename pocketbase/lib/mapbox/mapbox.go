@@ -1,9 +1,9 @@
 package mapbox
 
 import (
+	"app/lib/tilecache"
 	utils "app/lib/utils"
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"image"
 	"io/ioutil"
@@ -17,7 +17,11 @@ import (
 var baseUrl = "https://api.mapbox.com/v4"
 var accessToken = os.Getenv("VITE_MAPBOX_ACCESS_TOKEN")
 
-func DownloadTile(x int, y int, zoom int, typeStr string) image.Image {
+// TileCache is consulted before downloading a tile. Tiles are downloaded on
+// every request while it is nil.
+var TileCache *tilecache.Cache
+
+func DownloadTile(x int, y int, zoom int, typeStr string) (image.Image, error) {
 	type Params struct {
 		AccessToken string `url:"access_token"`
 	}
@@ -25,88 +29,131 @@ func DownloadTile(x int, y int, zoom int, typeStr string) image.Image {
 		AccessToken: accessToken,
 	}
 	url := fmt.Sprintf("%s/%s/%d/%d/%d@2x.png", baseUrl, typeStr, zoom, x, y)
+	cacheKey := fmt.Sprintf("mapbox/%s/%d/%d/%d", typeStr, zoom, x, y)
+
+	return fetchImage(cacheKey, url+"/", params)
+}
+
+// fetchImage downloads and decodes a tile, going through TileCache when one is
+// configured.
+func fetchImage(cacheKey string, url string, params interface{}) (image.Image, error) {
+	if TileCache != nil {
+		if body, ok := TileCache.Get(cacheKey); ok {
+			img, _, err := image.Decode(bytes.NewReader(body))
+			if err == nil {
+				return img, nil
+			}
+		}
+	}
+
 	log.Printf(url)
-	req, err := sling.New().Get(url + "/").QueryStruct(params).Request()
+	body, err := fetchBytes(url, params)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode the image
+	img, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		return nil, &DecodeError{URL: url, Err: err}
+	}
+
+	if TileCache != nil {
+		if err := TileCache.Put(cacheKey, body); err != nil {
+			log.Printf("Failed to cache tile %s: %v", cacheKey, err)
+		}
+	}
+
+	return img, nil
+}
 
+func fetchBytes(url string, params interface{}) ([]byte, error) {
+	req, err := sling.New().Get(url).QueryStruct(params).Request()
 	if err != nil {
-		fmt.Printf("Failed to prepare request: %v\n", err)
-		return nil
+		return nil, fmt.Errorf("failed to prepare request: %w", err)
 	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := DefaultClient.Do(req)
 	if err != nil {
-		fmt.Printf("Failed to download tile: %v\n", err)
-		return nil
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("Failed to download tile, HTTP status code: %d\n", resp.StatusCode)
-		return nil
+		return nil, &StatusError{URL: url, StatusCode: resp.StatusCode}
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Printf("Failed to read response body: %v\n", err)
-		return nil
-	}
-
-	// Decode the image
-	img, _, err := image.Decode(bytes.NewReader(body))
-	if err != nil {
-		fmt.Printf("Failed to decode image: %v\n", err)
-		return nil
+		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	return img
+	return body, nil
 }
 
-func DownloadSatelliteTile(x int, y int, zoom int) image.Image {
+func DownloadSatelliteTile(x int, y int, zoom int) (image.Image, error) {
 	return DownloadTile(x, y, zoom, "mapbox.satellite")
 }
 
-func DownloadHeightmapTile(x int, y int, zoom int) image.Image {
+func DownloadHeightmapTile(x int, y int, zoom int) (image.Image, error) {
 	return DownloadTile(x, y, zoom, "mapbox.terrain-rgb")
 }
 
-func ReadDistanceTable() (map[string]map[string]float64, error) {
-	// Open the JSON file
-	filePath := os.Getenv("DISTANCE_TABLE_PATH")
-	if filePath == "" {
-		// Default path when not specified by the environment variable
-		filePath = "./distance_table.json"
-	}
-	file, err := os.Open(filePath)
-	if err != nil {
-		fmt.Println("Error opening file:", err)
-		return nil, err
-	}
-	defer file.Close()
+// MapboxProvider serves imagery and terrain-rgb elevation from the Mapbox
+// raster tiles API.
+type MapboxProvider struct {
+	ImageryTileset   string
+	ElevationTileset string
+}
 
-	// Decode the JSON file into a map
-	var distanceTable map[string]map[string]float64
-	err = json.NewDecoder(file).Decode(&distanceTable)
-	if err != nil {
-		fmt.Println("Error decoding JSON:", err)
-		return nil, err
+func NewMapboxProvider() *MapboxProvider {
+	return &MapboxProvider{
+		ImageryTileset:   "mapbox.satellite",
+		ElevationTileset: "mapbox.terrain-rgb",
 	}
+}
+
+func (p *MapboxProvider) Name() string {
+	return "mapbox"
+}
+
+func (p *MapboxProvider) Encoding() Encoding {
+	return EncodingTerrainRGB
+}
 
-	return distanceTable, nil
+func (p *MapboxProvider) MaxZoom() int {
+	return 22
 }
 
-func MetersPerPixelForZoomAndLatitude(zoom int, latitude float64) float64 {
-	distanceTable, _ := ReadDistanceTable()
-	// round latitude to closest 20
-	latitude = float64(int(latitude/20)) * 20
-	value, _ := distanceTable[fmt.Sprintf("%d", zoom)][fmt.Sprintf("%f", latitude)]
+func (p *MapboxProvider) TileSize() int {
+	return TileSize
+}
+
+func (p *MapboxProvider) FetchImagery(x int, y int, zoom int) (image.Image, error) {
+	return p.fetch(p.ImageryTileset, x, y, zoom)
+}
+
+func (p *MapboxProvider) FetchElevation(x int, y int, zoom int) (image.Image, error) {
+	return p.fetch(p.ElevationTileset, x, y, zoom)
+}
+
+func (p *MapboxProvider) fetch(tileset string, x int, y int, zoom int) (image.Image, error) {
+	if err := checkZoom(p, zoom); err != nil {
+		return nil, err
+	}
 
-	return value
+	return DownloadTile(x, y, zoom, tileset)
 }
 
-func MeterPerPixelFromBboxAndZoom(zoom int, bboxString string) float64 {
-	values := utils.CoordsFromBboxString(bboxString)
-	_, lat := utils.GetCenterOfBbox(values)
+func MeterPerPixelFromBboxAndZoom(zoom int, bboxString string, tileSize int) (float64, error) {
+	values, err := utils.CoordsFromBboxString(bboxString)
+	if err != nil {
+		return 0, err
+	}
+	_, lat, err := utils.GetCenterOfBbox(values)
+	if err != nil {
+		return 0, err
+	}
 
-	return MetersPerPixelForZoomAndLatitude(zoom, lat)
+	return GroundResolution(zoom, lat, tileSize), nil
 }
@@ -0,0 +1,115 @@
+package mapbox
+
+import (
+	"app/lib/elevation"
+	utils "app/lib/utils"
+	"fmt"
+	"image"
+	"os"
+	"sync"
+)
+
+// Encoding describes how elevation is packed into the pixels of an
+// elevation tile.
+type Encoding = elevation.Encoding
+
+const (
+	EncodingTerrainRGB = elevation.EncodingTerrainRGB
+	EncodingTerrarium  = elevation.EncodingTerrarium
+)
+
+// TileProvider is a source of imagery and elevation tiles addressed by
+// slippy-map x/y/zoom.
+type TileProvider interface {
+	Name() string
+	FetchImagery(x int, y int, zoom int) (image.Image, error)
+	FetchElevation(x int, y int, zoom int) (image.Image, error)
+	Encoding() Encoding
+	MaxZoom() int
+	// TileSize is the width and height of the provider's tiles in pixels.
+	TileSize() int
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]TileProvider{}
+)
+
+// RegisterProvider makes a provider selectable by name, replacing any
+// provider previously registered under the same name.
+func RegisterProvider(name string, provider TileProvider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[name] = provider
+}
+
+// DefaultProviderName is the provider used when a tile doesn't specify one,
+// taken from TILE_PROVIDER and falling back to mapbox.
+func DefaultProviderName() string {
+	if name := os.Getenv("TILE_PROVIDER"); name != "" {
+		return name
+	}
+	return "mapbox"
+}
+
+// ProviderForName returns the registered provider with the given name, or the
+// default provider if name is empty.
+func ProviderForName(name string) (TileProvider, error) {
+	if name == "" {
+		name = DefaultProviderName()
+	}
+
+	providersMu.RLock()
+	provider, ok := providers[name]
+	providersMu.RUnlock()
+	if ok {
+		return provider, nil
+	}
+
+	provider = providerFromEnv(name)
+	if provider == nil {
+		return nil, fmt.Errorf("unknown tile provider %q", name)
+	}
+	RegisterProvider(name, provider)
+
+	return provider, nil
+}
+
+func providerFromEnv(name string) TileProvider {
+	switch name {
+	case "mapbox":
+		return NewMapboxProvider()
+	case "xyz":
+		return &XYZProvider{
+			ProviderName:      "xyz",
+			ImageryURL:        os.Getenv("XYZ_IMAGERY_URL"),
+			ElevationURL:      os.Getenv("XYZ_ELEVATION_URL"),
+			TMS:               os.Getenv("XYZ_TMS") == "true",
+			ElevationEncoding: encodingFromEnv("XYZ_ENCODING"),
+			MaxZoomLevel:      utils.IntFromEnv("XYZ_MAX_ZOOM", 22),
+			TileSizePixels:    utils.IntFromEnv("XYZ_TILE_SIZE", 256),
+		}
+	case "file":
+		return &FileProvider{
+			Dir:               os.Getenv("TILE_DIRECTORY"),
+			ElevationEncoding: encodingFromEnv("TILE_DIRECTORY_ENCODING"),
+			MaxZoomLevel:      utils.IntFromEnv("TILE_DIRECTORY_MAX_ZOOM", 22),
+			TileSizePixels:    utils.IntFromEnv("TILE_DIRECTORY_TILE_SIZE", 256),
+		}
+	}
+	return nil
+}
+
+func encodingFromEnv(key string) Encoding {
+	if value := os.Getenv(key); value != "" {
+		return Encoding(value)
+	}
+	return EncodingTerrainRGB
+}
+
+func checkZoom(provider TileProvider, zoom int) error {
+	if zoom < 0 || zoom > provider.MaxZoom() {
+		return fmt.Errorf("zoom %d outside of range 0-%d for provider %s", zoom, provider.MaxZoom(), provider.Name())
+	}
+	return nil
+}
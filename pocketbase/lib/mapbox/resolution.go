@@ -0,0 +1,45 @@
+package mapbox
+
+import (
+	"app/lib/tilemath"
+	"math"
+)
+
+const (
+	// EarthRadius is the WGS84 semi-major axis used by Web Mercator, in meters.
+	EarthRadius = 6378137.0
+	// TileSize is the width of the @2x tiles we download, in pixels.
+	TileSize = 512
+)
+
+// GroundResolution returns how many meters on the ground one pixel of a Web
+// Mercator tile covers at the given zoom and latitude. Mapbox @2x tiles are
+// TileSize pixels, most xyz and file providers serve 256.
+func GroundResolution(zoom int, latitude float64, tileSize int) float64 {
+	latitude = math.Max(-tilemath.MaxLatitude, math.Min(tilemath.MaxLatitude, latitude))
+	return math.Cos(latitude*math.Pi/180) * 2 * math.Pi * EarthRadius / (float64(tileSize) * math.Exp2(float64(zoom)))
+}
+
+// MetersPerPixelForTileRows returns the ground resolution at the center of each
+// pixel row of tile y, from north to south. Within a tile the resolution
+// shrinks towards the poles, which matters for low zoom levels.
+func MetersPerPixelForTileRows(y int, zoom int, tileSize int) []float64 {
+	rows := make([]float64, tileSize)
+	for row := range rows {
+		latitude := tilemath.TileToLat(float64(y)+(float64(row)+0.5)/float64(tileSize), zoom)
+		rows[row] = GroundResolution(zoom, latitude, tileSize)
+	}
+	return rows
+}
+
+// ZoomForMetersPerPixel returns the lowest zoom whose resolution at latitude
+// for tileSize pixel tiles is at least as fine as metersPerPixel, capped at
+// maxZoom.
+func ZoomForMetersPerPixel(metersPerPixel float64, latitude float64, maxZoom int, tileSize int) int {
+	for zoom := 0; zoom < maxZoom; zoom++ {
+		if GroundResolution(zoom, latitude, tileSize) <= metersPerPixel {
+			return zoom
+		}
+	}
+	return maxZoom
+}
@@ -0,0 +1,90 @@
+package mapbox
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGroundResolution(t *testing.T) {
+	tests := []struct {
+		zoom     int
+		latitude float64
+		tileSize int
+		want     float64
+	}{
+		// the reference value is 2πR / 256 at the equator
+		{0, 0, 256, 156543.03392804097},
+		{0, 0, 512, 78271.51696402048},
+		{10, 0, 256, 152.8740565703525},
+		{10, 0, 512, 76.43702828517625},
+		{12, 60, 256, 19.109257071294063},
+		{12, 60, 512, 9.554628535647032},
+		{12, -60, 512, 9.554628535647032},
+		{17, 45, 256, 0.8445178286593136},
+		// latitudes past Web Mercator's limit are clamped
+		{5, 89, 256, 422.01427955904063},
+		{5, -89, 256, 422.01427955904063},
+	}
+	for _, test := range tests {
+		got := GroundResolution(test.zoom, test.latitude, test.tileSize)
+		if math.Abs(got-test.want) > 1e-9*test.want {
+			t.Errorf("GroundResolution(%d, %v, %d) = %v, want %v", test.zoom, test.latitude, test.tileSize, got, test.want)
+		}
+	}
+}
+
+func TestMetersPerPixelHalvesWithTileSize(t *testing.T) {
+	for zoom := 0; zoom <= 22; zoom++ {
+		small := GroundResolution(zoom, 37.5, 256)
+		large := GroundResolution(zoom, 37.5, 512)
+		if math.Abs(small/large-2) > 1e-12 {
+			t.Errorf("zoom %d: 256px %v is not twice 512px %v", zoom, small, large)
+		}
+	}
+}
+
+func TestZoomForMetersPerPixel(t *testing.T) {
+	tests := []struct {
+		metersPerPixel float64
+		latitude       float64
+		maxZoom        int
+		tileSize       int
+		want           int
+	}{
+		{10, 0, 22, 256, 14},
+		{10, 0, 22, 512, 13},
+		{10, 60, 22, 256, 13},
+		{160000, 0, 22, 256, 0},
+		{0.001, 0, 18, 512, 18},
+	}
+	for _, test := range tests {
+		got := ZoomForMetersPerPixel(test.metersPerPixel, test.latitude, test.maxZoom, test.tileSize)
+		if got != test.want {
+			t.Errorf("ZoomForMetersPerPixel(%v, %v, %d, %d) = %d, want %d", test.metersPerPixel, test.latitude, test.maxZoom, test.tileSize, got, test.want)
+		}
+		if got < test.maxZoom && GroundResolution(got, test.latitude, test.tileSize) > test.metersPerPixel {
+			t.Errorf("zoom %d is coarser than %v m/px", got, test.metersPerPixel)
+		}
+	}
+}
+
+func TestMetersPerPixelForTileRows(t *testing.T) {
+	for _, tileSize := range []int{256, 512} {
+		// a northern hemisphere tile at zoom 2 spans 0° to 66.5°
+		rows := MetersPerPixelForTileRows(1, 2, tileSize)
+		if len(rows) != tileSize {
+			t.Fatalf("%d rows, want %d", len(rows), tileSize)
+		}
+		for i := 1; i < len(rows); i++ {
+			if rows[i] <= rows[i-1] {
+				t.Fatalf("tileSize %d: row %d (%v) isn't finer than the row south of it (%v)", tileSize, i-1, rows[i-1], rows[i])
+			}
+		}
+
+		// the southern edge of the tile is the equator
+		equator := GroundResolution(2, 0, tileSize)
+		if last := rows[len(rows)-1]; math.Abs(last-equator)/equator > 1e-3 {
+			t.Errorf("tileSize %d: last row %v, want about %v", tileSize, last, equator)
+		}
+	}
+}
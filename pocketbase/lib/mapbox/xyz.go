@@ -0,0 +1,126 @@
+package mapbox
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// XYZProvider fetches tiles from URL templates containing {x}, {y} and {z}
+// placeholders. {-y} or TMS flips the y axis for TMS tile servers.
+type XYZProvider struct {
+	ProviderName      string
+	ImageryURL        string
+	ElevationURL      string
+	TMS               bool
+	ElevationEncoding Encoding
+	MaxZoomLevel      int
+	// TileSizePixels is the size of the served tiles, 256 when 0.
+	TileSizePixels int
+}
+
+func (p *XYZProvider) Name() string {
+	return p.ProviderName
+}
+
+func (p *XYZProvider) Encoding() Encoding {
+	return p.ElevationEncoding
+}
+
+func (p *XYZProvider) MaxZoom() int {
+	return p.MaxZoomLevel
+}
+
+func (p *XYZProvider) TileSize() int {
+	return tileSizeOrDefault(p.TileSizePixels)
+}
+
+func (p *XYZProvider) FetchImagery(x int, y int, zoom int) (image.Image, error) {
+	return p.fetch("imagery", p.ImageryURL, x, y, zoom)
+}
+
+func (p *XYZProvider) FetchElevation(x int, y int, zoom int) (image.Image, error) {
+	return p.fetch("elevation", p.ElevationURL, x, y, zoom)
+}
+
+func (p *XYZProvider) fetch(kind string, template string, x int, y int, zoom int) (image.Image, error) {
+	if template == "" {
+		return nil, fmt.Errorf("no url template configured for provider %s", p.Name())
+	}
+	if err := checkZoom(p, zoom); err != nil {
+		return nil, err
+	}
+
+	cacheKey := fmt.Sprintf("%s/%s/%d/%d/%d", p.Name(), kind, zoom, x, y)
+	return fetchImage(cacheKey, expandTemplate(template, x, y, zoom, p.TMS), nil)
+}
+
+func tileSizeOrDefault(size int) int {
+	if size <= 0 {
+		return 256
+	}
+	return size
+}
+
+func expandTemplate(template string, x int, y int, zoom int, tms bool) string {
+	flippedY := (1 << zoom) - 1 - y
+	if tms {
+		y = flippedY
+	}
+
+	replacer := strings.NewReplacer(
+		"{x}", strconv.Itoa(x),
+		"{y}", strconv.Itoa(y),
+		"{-y}", strconv.Itoa(flippedY),
+		"{z}", strconv.Itoa(zoom),
+	)
+	return replacer.Replace(template)
+}
+
+// FileProvider reads tiles from a local directory laid out as
+// imagery/{z}/{x}/{y}.png and elevation/{z}/{x}/{y}.png. It stands in for a
+// tile server when running offline or in tests.
+type FileProvider struct {
+	Dir               string
+	ElevationEncoding Encoding
+	MaxZoomLevel      int
+	// TileSizePixels is the size of the stored tiles, 256 when 0.
+	TileSizePixels int
+}
+
+func (p *FileProvider) Name() string {
+	return "file"
+}
+
+func (p *FileProvider) Encoding() Encoding {
+	return p.ElevationEncoding
+}
+
+func (p *FileProvider) MaxZoom() int {
+	return p.MaxZoomLevel
+}
+
+func (p *FileProvider) TileSize() int {
+	return tileSizeOrDefault(p.TileSizePixels)
+}
+
+func (p *FileProvider) FetchImagery(x int, y int, zoom int) (image.Image, error) {
+	return p.open("imagery", x, y, zoom)
+}
+
+func (p *FileProvider) FetchElevation(x int, y int, zoom int) (image.Image, error) {
+	return p.open("elevation", x, y, zoom)
+}
+
+func (p *FileProvider) open(kind string, x int, y int, zoom int) (image.Image, error) {
+	if err := checkZoom(p, zoom); err != nil {
+		return nil, err
+	}
+
+	filePath := filepath.Join(p.Dir, kind, strconv.Itoa(zoom), strconv.Itoa(x), strconv.Itoa(y)+".png")
+	return imaging.Open(filePath)
+}
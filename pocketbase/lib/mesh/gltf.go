@@ -0,0 +1,149 @@
+package mesh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+)
+
+const (
+	glbMagic     = 0x46546C67 // "glTF"
+	glbChunkJSON = 0x4E4F534A // "JSON"
+	glbChunkBIN  = 0x004E4942 // "BIN\0"
+
+	componentFloat  = 5126
+	componentUint32 = 5125
+	targetArray     = 34962
+	targetElements  = 34963
+)
+
+// glbBuffer collects the binary chunk of a GLB file together with the
+// bufferViews pointing into it.
+type glbBuffer struct {
+	data  bytes.Buffer
+	views []map[string]any
+}
+
+// add appends data as a new bufferView and returns its index. Every view
+// starts on a 4-byte boundary as the spec requires for float and uint32 data.
+func (b *glbBuffer) add(data any, target int) int {
+	for b.data.Len()%4 != 0 {
+		b.data.WriteByte(0)
+	}
+	offset := b.data.Len()
+	binary.Write(&b.data, binary.LittleEndian, data)
+
+	view := map[string]any{
+		"buffer":     0,
+		"byteOffset": offset,
+		"byteLength": b.data.Len() - offset,
+	}
+	if target != 0 {
+		view["target"] = target
+	}
+	b.views = append(b.views, view)
+	return len(b.views) - 1
+}
+
+// WriteGLB writes the mesh as binary glTF 2.0. When texturePNG is given it is
+// embedded and draped over the mesh with the mesh's UVs.
+func (m *Mesh) WriteGLB(w io.Writer, texturePNG []byte) error {
+	buffer := &glbBuffer{}
+	min, max := m.Bounds()
+
+	accessors := []map[string]any{
+		{
+			"bufferView":    buffer.add(m.Positions, targetArray),
+			"componentType": componentFloat,
+			"count":         m.VertexCount(),
+			"type":          "VEC3",
+			"min":           min,
+			"max":           max,
+		},
+		{
+			"bufferView":    buffer.add(m.Normals, targetArray),
+			"componentType": componentFloat,
+			"count":         m.VertexCount(),
+			"type":          "VEC3",
+		},
+		{
+			"bufferView":    buffer.add(m.UVs, targetArray),
+			"componentType": componentFloat,
+			"count":         m.VertexCount(),
+			"type":          "VEC2",
+		},
+		{
+			"bufferView":    buffer.add(m.Indices, targetElements),
+			"componentType": componentUint32,
+			"count":         len(m.Indices),
+			"type":          "SCALAR",
+		},
+	}
+
+	material := map[string]any{
+		"name": "terrain",
+		"pbrMetallicRoughness": map[string]any{
+			"metallicFactor":  0,
+			"roughnessFactor": 1,
+		},
+	}
+
+	document := map[string]any{
+		"asset":  map[string]any{"version": "2.0", "generator": "terrain-creator"},
+		"scene":  0,
+		"scenes": []map[string]any{{"nodes": []int{0}}},
+		"nodes":  []map[string]any{{"name": "terrain", "mesh": 0}},
+		"meshes": []map[string]any{{
+			"name": "terrain",
+			"primitives": []map[string]any{{
+				"attributes": map[string]int{"POSITION": 0, "NORMAL": 1, "TEXCOORD_0": 2},
+				"indices":    3,
+				"material":   0,
+				"mode":       4,
+			}},
+		}},
+		"materials": []map[string]any{material},
+		"accessors": accessors,
+	}
+
+	if len(texturePNG) > 0 {
+		image := buffer.add(texturePNG, 0)
+		material["pbrMetallicRoughness"].(map[string]any)["baseColorTexture"] = map[string]any{"index": 0}
+		document["images"] = []map[string]any{{"bufferView": image, "mimeType": "image/png"}}
+		document["textures"] = []map[string]any{{"source": 0, "sampler": 0}}
+		// linear filtering with mipmaps, clamped so the edges don't bleed
+		document["samplers"] = []map[string]any{{"magFilter": 9729, "minFilter": 9987, "wrapS": 33071, "wrapT": 33071}}
+	}
+
+	for buffer.data.Len()%4 != 0 {
+		buffer.data.WriteByte(0)
+	}
+	document["bufferViews"] = buffer.views
+	document["buffers"] = []map[string]any{{"byteLength": buffer.data.Len()}}
+
+	jsonChunk, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+	for len(jsonChunk)%4 != 0 {
+		jsonChunk = append(jsonChunk, ' ')
+	}
+
+	binChunk := buffer.data.Bytes()
+	header := []uint32{
+		glbMagic, 2, uint32(12 + 8 + len(jsonChunk) + 8 + len(binChunk)),
+		uint32(len(jsonChunk)), glbChunkJSON,
+	}
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	if _, err := w.Write(jsonChunk); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, []uint32{uint32(len(binChunk)), glbChunkBIN}); err != nil {
+		return err
+	}
+	_, err = w.Write(binChunk)
+	return err
+}
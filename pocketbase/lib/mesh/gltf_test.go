@@ -0,0 +1,163 @@
+package mesh
+
+import (
+	"app/lib/elevation"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+)
+
+type glbDocument struct {
+	Accessors []struct {
+		BufferView    int       `json:"bufferView"`
+		ComponentType int       `json:"componentType"`
+		Count         int       `json:"count"`
+		Type          string    `json:"type"`
+		Min           []float32 `json:"min"`
+		Max           []float32 `json:"max"`
+	} `json:"accessors"`
+	BufferViews []struct {
+		ByteOffset int `json:"byteOffset"`
+		ByteLength int `json:"byteLength"`
+	} `json:"bufferViews"`
+	Buffers []struct {
+		ByteLength int `json:"byteLength"`
+	} `json:"buffers"`
+	Images []struct {
+		BufferView int    `json:"bufferView"`
+		MimeType   string `json:"mimeType"`
+	} `json:"images"`
+	Textures []struct {
+		Source int `json:"source"`
+	} `json:"textures"`
+}
+
+// readGLB splits a GLB file into its JSON document and binary chunk,
+// checking the lengths in the headers on the way.
+func readGLB(t *testing.T, data []byte) (glbDocument, []byte) {
+	t.Helper()
+
+	var header [5]uint32
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &header); err != nil {
+		t.Fatal(err)
+	}
+	if header[0] != glbMagic || header[1] != 2 {
+		t.Fatalf("magic %#x version %d, want %#x version 2", header[0], header[1], glbMagic)
+	}
+	if int(header[2]) != len(data) {
+		t.Fatalf("header length %d, file is %d bytes", header[2], len(data))
+	}
+	jsonLength := int(header[3])
+	if header[4] != glbChunkJSON || jsonLength%4 != 0 {
+		t.Fatalf("first chunk type %#x length %d, want a JSON chunk of 4-byte aligned length", header[4], jsonLength)
+	}
+
+	document := glbDocument{}
+	if err := json.Unmarshal(data[20:20+jsonLength], &document); err != nil {
+		t.Fatal(err)
+	}
+
+	rest := data[20+jsonLength:]
+	binLength := int(binary.LittleEndian.Uint32(rest))
+	if binary.LittleEndian.Uint32(rest[4:]) != glbChunkBIN || binLength != len(rest)-8 {
+		t.Fatalf("second chunk length %d of %d bytes, want a BIN chunk filling the file", binLength, len(rest)-8)
+	}
+	return document, rest[8:]
+}
+
+func TestWriteGLB(t *testing.T) {
+	grid := elevation.NewGrid(3, 2)
+	for i := range grid.Values {
+		grid.Values[i] = float32(i)
+	}
+	m := FromGrid(grid, 20, 10)
+	texture := []byte("\x89PNG not really")
+
+	var out bytes.Buffer
+	if err := m.WriteGLB(&out, texture); err != nil {
+		t.Fatal(err)
+	}
+	document, bin := readGLB(t, out.Bytes())
+
+	if len(document.Buffers) != 1 || document.Buffers[0].ByteLength != len(bin) {
+		t.Fatalf("buffers %+v, want one of %d bytes", document.Buffers, len(bin))
+	}
+	for i, view := range document.BufferViews {
+		if view.ByteOffset%4 != 0 || view.ByteOffset+view.ByteLength > len(bin) {
+			t.Errorf("bufferView %d at %d+%d doesn't fit aligned in %d bytes", i, view.ByteOffset, view.ByteLength, len(bin))
+		}
+	}
+
+	tests := []struct {
+		typ       string
+		component int
+		count     int
+		size      int
+	}{
+		{"VEC3", componentFloat, 6, 6 * 3 * 4},
+		{"VEC3", componentFloat, 6, 6 * 3 * 4},
+		{"VEC2", componentFloat, 6, 6 * 2 * 4},
+		{"SCALAR", componentUint32, 12, 12 * 4},
+	}
+	if len(document.Accessors) != len(tests) {
+		t.Fatalf("%d accessors, want %d", len(document.Accessors), len(tests))
+	}
+	for i, test := range tests {
+		accessor := document.Accessors[i]
+		if accessor.Type != test.typ || accessor.ComponentType != test.component || accessor.Count != test.count {
+			t.Errorf("accessor %d is %d %s of %d, want %d %s of %d", i, accessor.Count, accessor.Type, accessor.ComponentType, test.count, test.typ, test.component)
+		}
+		if got := document.BufferViews[accessor.BufferView].ByteLength; got != test.size {
+			t.Errorf("accessor %d views %d bytes, want %d", i, got, test.size)
+		}
+	}
+
+	positions := document.BufferViews[document.Accessors[0].BufferView]
+	decoded := make([]float32, len(m.Positions))
+	binary.Read(bytes.NewReader(bin[positions.ByteOffset:]), binary.LittleEndian, decoded)
+	for i := range decoded {
+		if decoded[i] != m.Positions[i] {
+			t.Fatalf("position %d = %v, want %v", i, decoded[i], m.Positions[i])
+		}
+	}
+	if min := document.Accessors[0].Min; len(min) != 3 || min[0] != -10 || min[1] != 0 || min[2] != -5 {
+		t.Errorf("position min %v, want [-10 0 -5]", min)
+	}
+	if max := document.Accessors[0].Max; len(max) != 3 || max[0] != 10 || max[1] != 5 || max[2] != 5 {
+		t.Errorf("position max %v, want [10 5 5]", max)
+	}
+
+	indices := document.BufferViews[document.Accessors[3].BufferView]
+	decodedIndices := make([]uint32, len(m.Indices))
+	binary.Read(bytes.NewReader(bin[indices.ByteOffset:]), binary.LittleEndian, decodedIndices)
+	for i := range decodedIndices {
+		if decodedIndices[i] != m.Indices[i] {
+			t.Fatalf("index %d = %d, want %d", i, decodedIndices[i], m.Indices[i])
+		}
+	}
+
+	if len(document.Images) != 1 || document.Images[0].MimeType != "image/png" || len(document.Textures) != 1 {
+		t.Fatalf("images %+v textures %+v, want one PNG texture", document.Images, document.Textures)
+	}
+	image := document.BufferViews[document.Images[0].BufferView]
+	if got := bin[image.ByteOffset : image.ByteOffset+image.ByteLength]; !bytes.Equal(got, texture) {
+		t.Errorf("embedded texture %q, want %q", got, texture)
+	}
+}
+
+func TestWriteGLBWithoutTexture(t *testing.T) {
+	m := FromGrid(elevation.NewGrid(2, 2), 1, 1)
+
+	var out bytes.Buffer
+	if err := m.WriteGLB(&out, nil); err != nil {
+		t.Fatal(err)
+	}
+	document, _ := readGLB(t, out.Bytes())
+	if len(document.Images) != 0 || len(document.Textures) != 0 {
+		t.Errorf("images %+v textures %+v, want none", document.Images, document.Textures)
+	}
+	if len(document.BufferViews) != 4 {
+		t.Errorf("%d bufferViews, want 4", len(document.BufferViews))
+	}
+}
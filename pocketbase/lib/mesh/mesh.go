@@ -0,0 +1,118 @@
+package mesh
+
+import (
+	"app/lib/elevation"
+	"math"
+)
+
+// Mesh is an indexed triangle mesh. Positions are in meters with x pointing
+// east, y up and z south, which keeps the mesh right-handed and Y-up as glTF
+// expects. UVs map the north-west corner of the terrain to (0, 0).
+type Mesh struct {
+	Positions []float32
+	Normals   []float32
+	UVs       []float32
+	Indices   []uint32
+}
+
+func (m *Mesh) VertexCount() int {
+	return len(m.Positions) / 3
+}
+
+// AddVertex adds a vertex at fractional position (u, v) across a terrain
+// width by length meters, centered on the origin, and returns its index.
+func (m *Mesh) AddVertex(u float64, v float64, height float64, width float64, length float64) uint32 {
+	index := uint32(m.VertexCount())
+	m.Positions = append(m.Positions,
+		float32((u-0.5)*width),
+		float32(height),
+		float32((v-0.5)*length),
+	)
+	m.UVs = append(m.UVs, float32(u), float32(v))
+	return index
+}
+
+// FromGrid builds a regular grid mesh with one vertex per grid value, the
+// grid covering width by length meters.
+func FromGrid(grid *elevation.Grid, width float64, length float64) *Mesh {
+	m := &Mesh{}
+	for y := 0; y < grid.Height; y++ {
+		for x := 0; x < grid.Width; x++ {
+			u := float64(x) / float64(grid.Width-1)
+			v := float64(y) / float64(grid.Height-1)
+			m.AddVertex(u, v, grid.At(x, y), width, length)
+		}
+	}
+
+	for y := 0; y < grid.Height-1; y++ {
+		for x := 0; x < grid.Width-1; x++ {
+			topLeft := uint32(y*grid.Width + x)
+			topRight := topLeft + 1
+			bottomLeft := topLeft + uint32(grid.Width)
+			bottomRight := bottomLeft + 1
+			// counter-clockwise when seen from above
+			m.Indices = append(m.Indices,
+				topLeft, bottomLeft, topRight,
+				topRight, bottomLeft, bottomRight,
+			)
+		}
+	}
+
+	m.ComputeNormals()
+	return m
+}
+
+// ComputeNormals sets every vertex normal to the area weighted average of the
+// normals of the triangles around it.
+func (m *Mesh) ComputeNormals() {
+	normals := make([]float64, len(m.Positions))
+	position := func(i uint32) (float64, float64, float64) {
+		return float64(m.Positions[i*3]), float64(m.Positions[i*3+1]), float64(m.Positions[i*3+2])
+	}
+
+	for i := 0; i+2 < len(m.Indices); i += 3 {
+		a, b, c := m.Indices[i], m.Indices[i+1], m.Indices[i+2]
+		ax, ay, az := position(a)
+		bx, by, bz := position(b)
+		cx, cy, cz := position(c)
+
+		// the cross product's length is twice the triangle's area
+		ux, uy, uz := bx-ax, by-ay, bz-az
+		vx, vy, vz := cx-ax, cy-ay, cz-az
+		nx, ny, nz := uy*vz-uz*vy, uz*vx-ux*vz, ux*vy-uy*vx
+
+		for _, vertex := range []uint32{a, b, c} {
+			normals[vertex*3] += nx
+			normals[vertex*3+1] += ny
+			normals[vertex*3+2] += nz
+		}
+	}
+
+	m.Normals = make([]float32, len(normals))
+	for i := 0; i < len(normals); i += 3 {
+		length := math.Sqrt(normals[i]*normals[i] + normals[i+1]*normals[i+1] + normals[i+2]*normals[i+2])
+		if length == 0 {
+			m.Normals[i+1] = 1
+			continue
+		}
+		m.Normals[i] = float32(normals[i] / length)
+		m.Normals[i+1] = float32(normals[i+1] / length)
+		m.Normals[i+2] = float32(normals[i+2] / length)
+	}
+}
+
+// Bounds returns the minimum and maximum position on each axis.
+func (m *Mesh) Bounds() ([3]float32, [3]float32) {
+	min := [3]float32{math.MaxFloat32, math.MaxFloat32, math.MaxFloat32}
+	max := [3]float32{-math.MaxFloat32, -math.MaxFloat32, -math.MaxFloat32}
+	for i, value := range m.Positions {
+		axis := i % 3
+		if value < min[axis] {
+			min[axis] = value
+		}
+		if value > max[axis] {
+			max[axis] = value
+		}
+	}
+	return min, max
+}
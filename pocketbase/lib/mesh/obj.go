@@ -0,0 +1,49 @@
+package mesh
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// WriteOBJ writes the mesh as Wavefront OBJ using the material "terrain" from
+// mtlFile. OBJ texture coordinates start at the bottom, so v is flipped.
+func (m *Mesh) WriteOBJ(w io.Writer, mtlFile string) error {
+	out := bufio.NewWriter(w)
+	if mtlFile != "" {
+		fmt.Fprintf(out, "mtllib %s\n", mtlFile)
+	}
+	fmt.Fprintln(out, "o terrain")
+
+	for i := 0; i < m.VertexCount(); i++ {
+		fmt.Fprintf(out, "v %g %g %g\n", m.Positions[i*3], m.Positions[i*3+1], m.Positions[i*3+2])
+	}
+	for i := 0; i < m.VertexCount(); i++ {
+		fmt.Fprintf(out, "vt %g %g\n", m.UVs[i*2], 1-m.UVs[i*2+1])
+	}
+	for i := 0; i < m.VertexCount(); i++ {
+		fmt.Fprintf(out, "vn %g %g %g\n", m.Normals[i*3], m.Normals[i*3+1], m.Normals[i*3+2])
+	}
+
+	if mtlFile != "" {
+		fmt.Fprintln(out, "usemtl terrain")
+	}
+	for i := 0; i+2 < len(m.Indices); i += 3 {
+		// OBJ indices start at 1
+		a, b, c := m.Indices[i]+1, m.Indices[i+1]+1, m.Indices[i+2]+1
+		fmt.Fprintf(out, "f %d/%d/%d %d/%d/%d %d/%d/%d\n", a, a, a, b, b, b, c, c, c)
+	}
+
+	return out.Flush()
+}
+
+// WriteMTL writes the material "terrain" referenced by WriteOBJ, textured
+// with textureFile when it is set.
+func WriteMTL(w io.Writer, textureFile string) error {
+	_, err := fmt.Fprintf(w, "newmtl terrain\nKa 1 1 1\nKd 1 1 1\nKs 0 0 0\nillum 1\n")
+	if err != nil || textureFile == "" {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "map_Kd %s\n", textureFile)
+	return err
+}
@@ -0,0 +1,101 @@
+package mesh
+
+import (
+	"app/lib/elevation"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestWriteOBJ(t *testing.T) {
+	grid := elevation.NewGrid(3, 2)
+	for i := range grid.Values {
+		grid.Values[i] = float32(i)
+	}
+	m := FromGrid(grid, 20, 10)
+
+	var out bytes.Buffer
+	if err := m.WriteOBJ(&out, "tile.mtl"); err != nil {
+		t.Fatal(err)
+	}
+
+	var positions, uvs [][]float32
+	normals := 0
+	var faces [][3]int
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if lines[0] != "mtllib tile.mtl" {
+		t.Errorf("first line %q, want mtllib tile.mtl", lines[0])
+	}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "v":
+			var x, y, z float32
+			fmt.Sscan(strings.Join(fields[1:], " "), &x, &y, &z)
+			positions = append(positions, []float32{x, y, z})
+		case "vt":
+			var u, v float32
+			fmt.Sscan(strings.Join(fields[1:], " "), &u, &v)
+			uvs = append(uvs, []float32{u, v})
+		case "vn":
+			normals++
+		case "f":
+			var face [3]int
+			for i, corner := range fields[1:] {
+				var v, vt, vn int
+				if _, err := fmt.Sscanf(corner, "%d/%d/%d", &v, &vt, &vn); err != nil || v != vt || v != vn {
+					t.Fatalf("face corner %q, want v/v/v", corner)
+				}
+				face[i] = v
+			}
+			faces = append(faces, face)
+		}
+	}
+
+	if len(positions) != 6 || len(uvs) != 6 || normals != 6 {
+		t.Fatalf("%d v, %d vt, %d vn, want 6 each", len(positions), len(uvs), normals)
+	}
+	for i, position := range positions {
+		for axis := 0; axis < 3; axis++ {
+			if position[axis] != m.Positions[i*3+axis] {
+				t.Errorf("v %d = %v, want %v", i+1, position, m.Positions[i*3:i*3+3])
+				break
+			}
+		}
+		// v counts from the bottom in OBJ
+		if uvs[i][0] != m.UVs[i*2] || uvs[i][1] != 1-m.UVs[i*2+1] {
+			t.Errorf("vt %d = %v, want [%v %v]", i+1, uvs[i], m.UVs[i*2], 1-m.UVs[i*2+1])
+		}
+	}
+
+	if len(faces) != len(m.Indices)/3 {
+		t.Fatalf("%d faces, want %d", len(faces), len(m.Indices)/3)
+	}
+	for i, face := range faces {
+		for corner := 0; corner < 3; corner++ {
+			if want := int(m.Indices[i*3+corner]) + 1; face[corner] != want {
+				t.Errorf("face %d = %v, want 1-based indices of %v", i, face, m.Indices[i*3:i*3+3])
+				break
+			}
+		}
+	}
+}
+
+func TestWriteMTL(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteMTL(&out, "tile.png"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "newmtl terrain\n") || !strings.HasSuffix(out.String(), "map_Kd tile.png\n") {
+		t.Errorf("MTL %q, want material terrain textured with tile.png", out.String())
+	}
+
+	out.Reset()
+	if err := WriteMTL(&out, ""); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "map_Kd") {
+		t.Errorf("MTL %q has a texture, want none", out.String())
+	}
+}
@@ -0,0 +1,122 @@
+package mesh
+
+import (
+	"app/lib/elevation"
+	"fmt"
+	"math"
+)
+
+// RTIN is a right-triangulated irregular network over a square grid of
+// 2^n+1 values, following Mapbox's Martini. The error of every possible split
+// is computed once, after which meshes for any maximum error are cheap.
+type RTIN struct {
+	grid   *elevation.Grid
+	errors []float64
+}
+
+// RTINSize returns the smallest 2^n+1 size that holds size values.
+func RTINSize(size int) int {
+	n := 1
+	for n+1 < size {
+		n *= 2
+	}
+	return n + 1
+}
+
+// NewRTIN computes the approximation errors of a square 2^n+1 grid.
+func NewRTIN(grid *elevation.Grid) (*RTIN, error) {
+	size := grid.Width
+	if grid.Height != size || size < 2 || (size-1)&(size-2) != 0 {
+		return nil, fmt.Errorf("RTIN needs a square 2^n+1 grid, got %dx%d", grid.Width, grid.Height)
+	}
+
+	tileSize := size - 1
+	numTriangles := tileSize*tileSize*2 - 2
+	numParentTriangles := numTriangles - tileSize*tileSize
+
+	// walk from the smallest triangles up so every split knows the error of
+	// its children
+	errors := make([]float64, size*size)
+	for i := numTriangles - 1; i >= 0; i-- {
+		ax, ay, bx, by := hypotenuse(i+2, tileSize)
+		mx, my := (ax+bx)>>1, (ay+by)>>1
+		cx, cy := mx+my-ay, my+ax-mx
+
+		interpolated := (grid.At(ax, ay) + grid.At(bx, by)) / 2
+		middle := my*size + mx
+		errors[middle] = math.Max(errors[middle], math.Abs(interpolated-grid.At(mx, my)))
+
+		if i < numParentTriangles {
+			left := ((ay+cy)>>1)*size + ((ax + cx) >> 1)
+			right := ((by+cy)>>1)*size + ((bx + cx) >> 1)
+			errors[middle] = math.Max(errors[middle], math.Max(errors[left], errors[right]))
+		}
+	}
+
+	return &RTIN{grid: grid, errors: errors}, nil
+}
+
+// hypotenuse returns the two corners of the hypotenuse of triangle id in the
+// hierarchy, numbered as in Martini: 2 and 3 are the halves of the grid and
+// the children of id are 2*id and 2*id+1.
+func hypotenuse(id int, tileSize int) (int, int, int, int) {
+	ax, ay, bx, by, cx, cy := 0, 0, 0, 0, 0, 0
+	if id&1 == 1 {
+		bx, by, cx = tileSize, tileSize, tileSize
+	} else {
+		ax, ay, cy = tileSize, tileSize, tileSize
+	}
+	for id >>= 1; id > 1; id >>= 1 {
+		mx, my := (ax+bx)>>1, (ay+by)>>1
+		if id&1 == 1 {
+			bx, by = ax, ay
+			ax, ay = cx, cy
+		} else {
+			ax, ay = bx, by
+			bx, by = cx, cy
+		}
+		cx, cy = mx, my
+	}
+	return ax, ay, bx, by
+}
+
+// Mesh returns the coarsest mesh whose heights stay within maxError meters of
+// the grid, covering width by length meters.
+func (r *RTIN) Mesh(maxError float64, width float64, length float64) *Mesh {
+	size := r.grid.Width
+	last := size - 1
+	m := &Mesh{}
+
+	// vertex index + 1 for every grid point in use
+	vertices := make([]uint32, size*size)
+	vertex := func(x int, y int) uint32 {
+		if vertices[y*size+x] == 0 {
+			u, v := float64(x)/float64(last), float64(y)/float64(last)
+			vertices[y*size+x] = m.AddVertex(u, v, r.grid.At(x, y), width, length) + 1
+		}
+		return vertices[y*size+x] - 1
+	}
+
+	var split func(ax, ay, bx, by, cx, cy int)
+	split = func(ax, ay, bx, by, cx, cy int) {
+		mx, my := (ax+bx)>>1, (ay+by)>>1
+		if abs(ax-cx)+abs(ay-cy) > 1 && r.errors[my*size+mx] > maxError {
+			split(cx, cy, ax, ay, mx, my)
+			split(bx, by, cx, cy, mx, my)
+			return
+		}
+		m.Indices = append(m.Indices, vertex(ax, ay), vertex(bx, by), vertex(cx, cy))
+	}
+	split(0, 0, last, last, last, 0)
+	split(last, last, 0, 0, 0, last)
+
+	m.ComputeNormals()
+	return m
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
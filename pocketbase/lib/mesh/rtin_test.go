@@ -0,0 +1,99 @@
+package mesh
+
+import (
+	"app/lib/elevation"
+	"math"
+	"runtime"
+	"testing"
+)
+
+func TestRTINFlatGridIsTwoTriangles(t *testing.T) {
+	rtin, err := NewRTIN(elevation.NewGrid(65, 65))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := rtin.Mesh(0, 100, 100)
+	if got := len(m.Indices) / 3; got != 2 {
+		t.Errorf("%d triangles, want 2", got)
+	}
+}
+
+func TestRTINCoarsensWithMaxError(t *testing.T) {
+	grid := elevation.NewGrid(33, 33)
+	for y := 0; y < grid.Height; y++ {
+		for x := 0; x < grid.Width; x++ {
+			grid.Set(x, y, 100*math.Sin(float64(x)/5)*math.Cos(float64(y)/7))
+		}
+	}
+	rtin, err := NewRTIN(grid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	coarse, fine := rtin.Mesh(50, 1, 1), rtin.Mesh(-1, 1, 1)
+	if len(coarse.Indices) >= len(fine.Indices) {
+		t.Errorf("max error 50 gives %d indices, -1 gives %d", len(coarse.Indices), len(fine.Indices))
+	}
+	// a negative max error splits everything, so every grid point is a vertex
+	if got := fine.VertexCount(); got != 33*33 {
+		t.Errorf("%d vertices, want %d", got, 33*33)
+	}
+}
+
+func TestNewRTINRejectsOtherSizes(t *testing.T) {
+	for _, size := range [][2]int{{64, 64}, {65, 33}, {1, 1}} {
+		if _, err := NewRTIN(elevation.NewGrid(size[0], size[1])); err == nil {
+			t.Errorf("expected an error for a %dx%d grid", size[0], size[1])
+		}
+	}
+}
+
+// The largest grid the mesh endpoint accepts used to take 268MB of triangle
+// coordinates on top of the grid; now only the 34MB of errors remain.
+func TestNewRTINMemoryAtMaxResolution(t *testing.T) {
+	if testing.Short() {
+		t.Skip("allocates a 2049x2049 grid")
+	}
+	grid := elevation.NewGrid(2049, 2049)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	if _, err := NewRTIN(grid); err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 40<<20 {
+		t.Errorf("NewRTIN allocated %dMB", allocated>>20)
+	}
+}
+
+func TestHypotenuse(t *testing.T) {
+	const tileSize = 8
+	for _, root := range []int{2, 3} {
+		ax, ay, bx, by := hypotenuse(root, tileSize)
+		if ax+ay+bx+by != 2*tileSize || abs(ax-bx) != tileSize || abs(ay-by) != tileSize {
+			t.Errorf("root %d splits along %d,%d-%d,%d, want the diagonal", root, ax, ay, bx, by)
+		}
+	}
+
+	// every level halves the triangles, so hypotenuses shrink by √2 and the
+	// midpoints of a level are all different grid points
+	length := 2 * tileSize * tileSize
+	for first := 2; first < tileSize*tileSize*2; first *= 2 {
+		middles := map[[2]int]bool{}
+		for id := first; id < first*2; id++ {
+			ax, ay, bx, by := hypotenuse(id, tileSize)
+			if d := (ax-bx)*(ax-bx) + (ay-by)*(ay-by); d != length {
+				t.Fatalf("triangle %d: hypotenuse² %d, want %d", id, d, length)
+			}
+			middles[[2]int{(ax + bx) / 2, (ay + by) / 2}] = true
+		}
+		// the two triangles sharing a hypotenuse share its midpoint
+		if len(middles) < first/2 {
+			t.Errorf("level of %d triangles has %d midpoints", first, len(middles))
+		}
+		length /= 2
+	}
+}
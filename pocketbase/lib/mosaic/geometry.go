@@ -0,0 +1,120 @@
+package mosaic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"app/lib/tilemath"
+)
+
+// Polygon is a GeoJSON polygon: an outer ring followed by any holes, each a
+// list of [lon, lat] positions.
+type Polygon [][][2]float64
+
+type geoJSON struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometry    json.RawMessage `json:"geometry"`
+	Features    []geoJSON       `json:"features"`
+}
+
+// ParsePolygon accepts a GeoJSON Polygon, a Feature wrapping one, or a
+// FeatureCollection whose first feature is one.
+func ParsePolygon(raw []byte) (Polygon, error) {
+	var object geoJSON
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, err
+	}
+
+	switch object.Type {
+	case "Polygon":
+		var polygon Polygon
+		if err := json.Unmarshal(object.Coordinates, &polygon); err != nil {
+			return nil, err
+		}
+		return polygon, polygon.validate()
+	case "Feature":
+		return ParsePolygon(object.Geometry)
+	case "FeatureCollection":
+		if len(object.Features) == 0 {
+			return nil, errors.New("feature collection is empty")
+		}
+		return ParsePolygon(object.Features[0].Geometry)
+	}
+
+	return nil, fmt.Errorf("unsupported geometry type %q, expected a Polygon", object.Type)
+}
+
+// PolygonFromBBox returns the rectangle covering bbox.
+func PolygonFromBBox(bbox tilemath.BBox) Polygon {
+	return Polygon{{
+		{bbox.West(), bbox.South()},
+		{bbox.East(), bbox.South()},
+		{bbox.East(), bbox.North()},
+		{bbox.West(), bbox.North()},
+		{bbox.West(), bbox.South()},
+	}}
+}
+
+func (p Polygon) validate() error {
+	if len(p) == 0 || len(p[0]) < 4 {
+		return errors.New("polygon needs an outer ring of at least 4 positions")
+	}
+	for _, ring := range p {
+		for _, position := range ring {
+			if position[0] < -180 || position[0] > 180 || position[1] < -90 || position[1] > 90 {
+				return fmt.Errorf("position %v is outside of the valid lon/lat range", position)
+			}
+		}
+	}
+	if p.Area() == 0 {
+		return errors.New("polygon has no area")
+	}
+	return nil
+}
+
+// Area is the planar area of the polygon in square degrees, holes excluded.
+func (p Polygon) Area() float64 {
+	area := 0.0
+	for i, ring := range p {
+		ringArea := 0.0
+		for j := 0; j+1 < len(ring); j++ {
+			ringArea += ring[j][0]*ring[j+1][1] - ring[j+1][0]*ring[j][1]
+		}
+		if i == 0 {
+			area += math.Abs(ringArea) / 2
+		} else {
+			area -= math.Abs(ringArea) / 2
+		}
+	}
+	return math.Max(0, area)
+}
+
+func (p Polygon) BBox() tilemath.BBox {
+	bbox := tilemath.BBox{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, position := range p[0] {
+		bbox[0] = math.Min(bbox[0], position[0])
+		bbox[1] = math.Min(bbox[1], position[1])
+		bbox[2] = math.Max(bbox[2], position[0])
+		bbox[3] = math.Max(bbox[3], position[1])
+	}
+	return bbox
+}
+
+// contains reports whether the point lies inside the polygon using the
+// even-odd rule, so holes are excluded.
+func contains(rings [][][2]float64, x float64, y float64) bool {
+	inside := false
+	for _, ring := range rings {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			xi, yi := ring[i][0], ring[i][1]
+			xj, yj := ring[j][0], ring[j][1]
+			if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
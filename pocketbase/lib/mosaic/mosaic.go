@@ -0,0 +1,157 @@
+package mosaic
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"app/lib/tilemath"
+
+	"github.com/disintegration/imaging"
+)
+
+// Region is an area covered by the tiles of a single zoom level, stitched
+// together into one image and cropped to the polygon's bbox.
+type Region struct {
+	Polygon  Polygon
+	Zoom     int
+	TileSize int
+}
+
+type FetchFunc func(x int, y int, zoom int) (image.Image, error)
+
+// pixelBounds returns the region's bbox in world pixel coordinates at the
+// region's zoom.
+func (r Region) pixelBounds() image.Rectangle {
+	bbox := r.Polygon.BBox()
+	size := float64(r.TileSize)
+	return image.Rect(
+		int(math.Floor(tilemath.LonToTile(bbox.West(), r.Zoom)*size)),
+		int(math.Floor(tilemath.LatToTile(bbox.North(), r.Zoom)*size)),
+		int(math.Ceil(tilemath.LonToTile(bbox.East(), r.Zoom)*size)),
+		int(math.Ceil(tilemath.LatToTile(bbox.South(), r.Zoom)*size)),
+	)
+}
+
+// Tiles returns the tiles covering the region, row by row from the north-west.
+func (r Region) Tiles() []tilemath.Tile {
+	bounds := r.pixelBounds()
+	tiles := make([]tilemath.Tile, 0)
+	for y := bounds.Min.Y / r.TileSize; y <= (bounds.Max.Y-1)/r.TileSize; y++ {
+		for x := bounds.Min.X / r.TileSize; x <= (bounds.Max.X-1)/r.TileSize; x++ {
+			tiles = append(tiles, tilemath.Tile{X: x, Y: y, Z: r.Zoom})
+		}
+	}
+	return tiles
+}
+
+// BBox is the bbox of the stitched image, which is the polygon's bbox
+// snapped outwards to whole pixels.
+func (r Region) BBox() tilemath.BBox {
+	bounds := r.pixelBounds()
+	size := float64(r.TileSize)
+	return tilemath.BBox{
+		tilemath.TileToLon(float64(bounds.Min.X)/size, r.Zoom),
+		tilemath.TileToLat(float64(bounds.Max.Y)/size, r.Zoom),
+		tilemath.TileToLon(float64(bounds.Max.X)/size, r.Zoom),
+		tilemath.TileToLat(float64(bounds.Min.Y)/size, r.Zoom),
+	}
+}
+
+// Stitch fetches every covering tile and draws them into one image cropped
+// to the region's bbox. TileSize should be the provider's own tile size;
+// tiles of another size are resized with nearest neighbor so encoded
+// elevation values survive, and 16-bit gray tiles are
+// stitched into a 16-bit gray image so DEMs keep their precision.
+func (r Region) Stitch(fetch FetchFunc) (draw.Image, error) {
+	bounds := r.pixelBounds()
+
+	var mosaic draw.Image
+	for _, tile := range r.Tiles() {
+		img, err := fetch(tile.X, tile.Y, tile.Z)
+		if err != nil {
+			return nil, fmt.Errorf("tile %s: %w", tile, err)
+		}
+		if mosaic == nil {
+			mosaic = newMosaic(img, bounds.Dx(), bounds.Dy())
+		}
+		if img.Bounds().Dx() != r.TileSize || img.Bounds().Dy() != r.TileSize {
+			img = resizeNearest(img, r.TileSize)
+		}
+
+		offset := image.Pt(tile.X*r.TileSize-bounds.Min.X, tile.Y*r.TileSize-bounds.Min.Y)
+		target := image.Rectangle{Min: offset, Max: offset.Add(image.Pt(r.TileSize, r.TileSize))}
+		draw.Draw(mosaic, target, img, img.Bounds().Min, draw.Src)
+	}
+	if mosaic == nil {
+		return nil, errors.New("the region covers no tiles")
+	}
+
+	return mosaic, nil
+}
+
+// newMosaic returns an empty image for tiles like sample.
+func newMosaic(sample image.Image, width int, height int) draw.Image {
+	if _, ok := sample.(*image.Gray16); ok {
+		return image.NewGray16(image.Rect(0, 0, width, height))
+	}
+	return image.NewNRGBA(image.Rect(0, 0, width, height))
+}
+
+// resizeNearest resizes a tile to size x size with nearest neighbor,
+// keeping 16-bit gray tiles 16-bit.
+func resizeNearest(img image.Image, size int) image.Image {
+	gray, ok := img.(*image.Gray16)
+	if !ok {
+		return imaging.Resize(img, size, size, imaging.NearestNeighbor)
+	}
+
+	bounds := gray.Bounds()
+	resized := image.NewGray16(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			resized.SetGray16(x, y, gray.Gray16At(bounds.Min.X+x*bounds.Dx()/size, bounds.Min.Y+y*bounds.Dy()/size))
+		}
+	}
+	return resized
+}
+
+// Mask clears every pixel of a stitched image outside the polygon: NRGBA
+// pixels become transparent, 16-bit gray elevation becomes 0 and other
+// images take whatever color.Transparent converts to in their model.
+func (r Region) Mask(img draw.Image) {
+	bounds := r.pixelBounds()
+	size := float64(r.TileSize)
+
+	// project the polygon to the image's pixel space once
+	rings := make([][][2]float64, len(r.Polygon))
+	for i, ring := range r.Polygon {
+		rings[i] = make([][2]float64, len(ring))
+		for j, position := range ring {
+			rings[i][j] = [2]float64{
+				tilemath.LonToTile(position[0], r.Zoom)*size - float64(bounds.Min.X),
+				tilemath.LatToTile(position[1], r.Zoom)*size - float64(bounds.Min.Y),
+			}
+		}
+	}
+
+	imgBounds := img.Bounds()
+	for y := imgBounds.Min.Y; y < imgBounds.Max.Y; y++ {
+		for x := imgBounds.Min.X; x < imgBounds.Max.X; x++ {
+			if contains(rings, float64(x)+0.5, float64(y)+0.5) {
+				continue
+			}
+			switch masked := img.(type) {
+			case *image.NRGBA:
+				masked.Pix[masked.PixOffset(x, y)+3] = 0
+			case *image.Gray16:
+				masked.SetGray16(x, y, color.Gray16{})
+			default:
+				img.Set(x, y, color.Transparent)
+			}
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package mosaic
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+
+	"app/lib/tilemath"
+)
+
+// tileColor marks every pixel with its tile and position in the tile.
+func tileColor(tile tilemath.Tile, x int, y int) color.NRGBA {
+	return color.NRGBA{R: uint8(tile.X*16 + tile.Y), G: uint8(x), B: uint8(y), A: 255}
+}
+
+// fakeTiles serves size x size NRGBA tiles marked with tileColor and records
+// which tiles were fetched.
+func fakeTiles(size int, fetched *[]tilemath.Tile) FetchFunc {
+	return func(x int, y int, zoom int) (image.Image, error) {
+		tile := tilemath.Tile{X: x, Y: y, Z: zoom}
+		if fetched != nil {
+			*fetched = append(*fetched, tile)
+		}
+		img := image.NewNRGBA(image.Rect(0, 0, size, size))
+		for py := 0; py < size; py++ {
+			for px := 0; px < size; px++ {
+				img.SetNRGBA(px, py, tileColor(tile, px, py))
+			}
+		}
+		return img, nil
+	}
+}
+
+// fakeGrayTiles serves size x size 16-bit tiles holding 1000*x + 100*y plus
+// the pixel's index.
+func fakeGrayTiles(size int) FetchFunc {
+	return func(x int, y int, zoom int) (image.Image, error) {
+		img := image.NewGray16(image.Rect(0, 0, size, size))
+		for py := 0; py < size; py++ {
+			for px := 0; px < size; px++ {
+				img.SetGray16(px, py, color.Gray16{Y: uint16(1000*x + 100*y + py*size + px)})
+			}
+		}
+		return img, nil
+	}
+}
+
+// tileSpan returns the polygon covering the tiles from nw to se inclusive.
+func tileSpan(nw tilemath.Tile, se tilemath.Tile) Polygon {
+	north, south := nw.Bounds(), se.Bounds()
+	return PolygonFromBBox(tilemath.BBox{north.West(), south.South(), south.East(), north.North()})
+}
+
+func TestRegionTiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		polygon Polygon
+		want    []tilemath.Tile
+	}{
+		{
+			"a bbox exactly on tile edges",
+			tileSpan(tilemath.Tile{X: 1, Y: 1, Z: 2}, tilemath.Tile{X: 1, Y: 1, Z: 2}),
+			[]tilemath.Tile{{X: 1, Y: 1, Z: 2}},
+		},
+		{
+			"a bbox across tile edges, row by row",
+			PolygonFromBBox(tilemath.BBox{-100, -10, 10, 60}),
+			[]tilemath.Tile{{X: 0, Y: 1, Z: 2}, {X: 1, Y: 1, Z: 2}, {X: 2, Y: 1, Z: 2}, {X: 0, Y: 2, Z: 2}, {X: 1, Y: 2, Z: 2}, {X: 2, Y: 2, Z: 2}},
+		},
+		{
+			// only the bbox of the triangle counts
+			"a triangle",
+			Polygon{{{-100, -10}, {10, -10}, {-100, 60}, {-100, -10}}},
+			[]tilemath.Tile{{X: 0, Y: 1, Z: 2}, {X: 1, Y: 1, Z: 2}, {X: 2, Y: 1, Z: 2}, {X: 0, Y: 2, Z: 2}, {X: 1, Y: 2, Z: 2}, {X: 2, Y: 2, Z: 2}},
+		},
+	}
+	for _, test := range tests {
+		// the covering tiles don't depend on the tile size
+		for _, size := range []int{256, 512} {
+			region := Region{Polygon: test.polygon, Zoom: 2, TileSize: size}
+			if got := region.Tiles(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("%s at %dpx: %v, want %v", test.name, size, got, test.want)
+			}
+		}
+	}
+}
+
+func TestRegionBBoxSnapsToPixels(t *testing.T) {
+	tile := tilemath.Tile{X: 1, Y: 1, Z: 2}
+	region := Region{Polygon: tileSpan(tile, tile), Zoom: 2, TileSize: 512}
+	if got := region.BBox(); !got.Equal(tile.Bounds(), 1e-9) {
+		t.Errorf("bbox %v, want the tile's %v", got, tile.Bounds())
+	}
+
+	// a bbox inside one pixel grows to that pixel
+	west := tilemath.TileToLon(1+10.2/4, 2)
+	east := tilemath.TileToLon(1+10.7/4, 2)
+	region = Region{Polygon: PolygonFromBBox(tilemath.BBox{west, -1, east, 1}), Zoom: 2, TileSize: 4}
+	bbox := region.BBox()
+	if want := tilemath.TileToLon(1+10.0/4, 2); bbox.West() != want {
+		t.Errorf("west %v, want %v", bbox.West(), want)
+	}
+	if want := tilemath.TileToLon(1+11.0/4, 2); bbox.East() != want {
+		t.Errorf("east %v, want %v", bbox.East(), want)
+	}
+}
+
+func TestStitchCanvasMatchesTileSize(t *testing.T) {
+	tile := tilemath.Tile{X: 1, Y: 1, Z: 2}
+	for _, size := range []int{256, 512} {
+		region := Region{Polygon: tileSpan(tile, tile), Zoom: 2, TileSize: size}
+		img, err := region.Stitch(fakeTiles(size, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bounds := img.Bounds(); bounds.Dx() != size || bounds.Dy() != size {
+			t.Errorf("%dpx tiles stitch into %v", size, bounds)
+		}
+		// drawn as is, not resampled
+		if got, want := img.At(size-1, 7), tileColor(tile, size-1, 7); got != want {
+			t.Errorf("%dpx tiles: pixel %v, want %v", size, got, want)
+		}
+	}
+}
+
+func TestStitchPlacesAndCropsTiles(t *testing.T) {
+	nw, se := tilemath.Tile{X: 1, Y: 1, Z: 2}, tilemath.Tile{X: 2, Y: 2, Z: 2}
+	var fetched []tilemath.Tile
+	region := Region{Polygon: tileSpan(nw, se), Zoom: 2, TileSize: 4}
+	img, err := region.Stitch(fakeTiles(4, &fetched))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fetched) != 4 {
+		t.Errorf("fetched %v, want 4 tiles", fetched)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != 8 || bounds.Dy() != 8 {
+		t.Fatalf("stitched %v, want 8x8", bounds)
+	}
+	for _, test := range []struct {
+		x, y   int
+		tile   tilemath.Tile
+		px, py int
+	}{
+		{0, 0, nw, 0, 0},
+		{5, 1, tilemath.Tile{X: 2, Y: 1, Z: 2}, 1, 1},
+		{2, 6, tilemath.Tile{X: 1, Y: 2, Z: 2}, 2, 2},
+		{7, 7, se, 3, 3},
+	} {
+		if got, want := img.At(test.x, test.y), tileColor(test.tile, test.px, test.py); got != want {
+			t.Errorf("pixel %d,%d = %v, want %v", test.x, test.y, got, want)
+		}
+	}
+
+	// half a tile wide: columns 2 and 3 of tile 1,1 only
+	west := tilemath.TileToLon(1.5, 2)
+	cropped := Region{Polygon: PolygonFromBBox(tilemath.BBox{west, nw.Bounds().South(), nw.Bounds().East(), nw.Bounds().North()}), Zoom: 2, TileSize: 4}
+	img, err = cropped.Stitch(fakeTiles(4, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != 2 || bounds.Dy() != 4 {
+		t.Fatalf("cropped %v, want 2x4", bounds)
+	}
+	if got, want := img.At(0, 3), tileColor(nw, 2, 3); got != want {
+		t.Errorf("cropped pixel %v, want %v", got, want)
+	}
+}
+
+func TestStitchResizesOtherTileSizes(t *testing.T) {
+	tile := tilemath.Tile{X: 1, Y: 1, Z: 2}
+	region := Region{Polygon: tileSpan(tile, tile), Zoom: 2, TileSize: 4}
+	img, err := region.Stitch(fakeTiles(2, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := img.At(3, 2), tileColor(tile, 1, 1); got != want {
+		t.Errorf("pixel %v, want %v", got, want)
+	}
+}
+
+func TestStitchKeepsGray16(t *testing.T) {
+	nw, se := tilemath.Tile{X: 1, Y: 1, Z: 2}, tilemath.Tile{X: 2, Y: 1, Z: 2}
+	for _, tileSize := range []int{4, 2} {
+		region := Region{Polygon: tileSpan(nw, se), Zoom: 2, TileSize: 4}
+		img, err := region.Stitch(fakeGrayTiles(tileSize))
+		if err != nil {
+			t.Fatal(err)
+		}
+		gray, ok := img.(*image.Gray16)
+		if !ok {
+			t.Fatalf("%dpx tiles stitched into %T, want *image.Gray16", tileSize, img)
+		}
+		// pixel 1,2 of tile 2,1, nearest neighbor when resized
+		want := uint16(2100 + 2*tileSize + 1)
+		if tileSize == 2 {
+			want = uint16(2100 + 1*tileSize + 0)
+		}
+		if got := gray.Gray16At(5, 2).Y; got != want {
+			t.Errorf("%dpx tiles: height %d, want %d", tileSize, got, want)
+		}
+	}
+}
+
+func TestStitchReportsFetchErrors(t *testing.T) {
+	tile := tilemath.Tile{X: 1, Y: 1, Z: 2}
+	broken := errors.New("tile server is down")
+	region := Region{Polygon: tileSpan(tile, tile), Zoom: 2, TileSize: 4}
+	_, err := region.Stitch(func(int, int, int) (image.Image, error) { return nil, broken })
+	if !errors.Is(err, broken) {
+		t.Errorf("err = %v, want %v", err, broken)
+	}
+}
+
+func TestMask(t *testing.T) {
+	tile := tilemath.Tile{X: 1, Y: 1, Z: 2}
+	bounds := tile.Bounds()
+	// the south-west half of the tile
+	triangle := Polygon{{
+		{bounds.West(), bounds.South()},
+		{bounds.East(), bounds.South()},
+		{bounds.West(), bounds.North()},
+		{bounds.West(), bounds.South()},
+	}}
+	region := Region{Polygon: triangle, Zoom: 2, TileSize: 4}
+
+	img, err := region.Stitch(fakeTiles(4, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	region.Mask(img)
+	nrgba := img.(*image.NRGBA)
+	if got := nrgba.NRGBAAt(0, 3).A; got != 255 {
+		t.Errorf("inside alpha %d, want 255", got)
+	}
+	if got := nrgba.NRGBAAt(3, 0).A; got != 0 {
+		t.Errorf("outside alpha %d, want 0", got)
+	}
+
+	img, err = region.Stitch(fakeGrayTiles(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	region.Mask(img)
+	gray := img.(*image.Gray16)
+	if got := gray.Gray16At(0, 3).Y; got != 1100+12 {
+		t.Errorf("inside height %d, want 1112", got)
+	}
+	if got := gray.Gray16At(3, 0).Y; got != 0 {
+		t.Errorf("outside height %d, want 0", got)
+	}
+}
+
+func TestParsePolygon(t *testing.T) {
+	square := `{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]}`
+	tests := []struct {
+		name  string
+		input string
+		ok    bool
+	}{
+		{"polygon", square, true},
+		{"feature", `{"type":"Feature","geometry":` + square + `}`, true},
+		{"feature collection", `{"type":"FeatureCollection","features":[{"type":"Feature","geometry":` + square + `}]}`, true},
+		{"empty feature collection", `{"type":"FeatureCollection","features":[]}`, false},
+		{"point", `{"type":"Point","coordinates":[0,0]}`, false},
+		{"too few positions", `{"type":"Polygon","coordinates":[[[0,0],[1,0],[0,0]]]}`, false},
+		{"out of range", `{"type":"Polygon","coordinates":[[[0,0],[181,0],[1,1],[0,0]]]}`, false},
+		{"no area", `{"type":"Polygon","coordinates":[[[0,0],[1,1],[2,2],[0,0]]]}`, false},
+		{"not json", `{`, false},
+	}
+	for _, test := range tests {
+		polygon, err := ParsePolygon([]byte(test.input))
+		if (err == nil) != test.ok {
+			t.Errorf("%s: err = %v", test.name, err)
+		}
+		if test.ok && polygon.Area() != 1 {
+			t.Errorf("%s: area %v, want 1", test.name, polygon.Area())
+		}
+	}
+}
+
+func TestPolygonAreaExcludesHoles(t *testing.T) {
+	polygon := Polygon{
+		{{0, 0}, {4, 0}, {4, 4}, {0, 4}, {0, 0}},
+		{{1, 1}, {2, 1}, {2, 2}, {1, 2}, {1, 1}},
+	}
+	if got := polygon.Area(); got != 15 {
+		t.Errorf("area %v, want 15", got)
+	}
+}
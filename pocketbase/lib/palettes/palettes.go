@@ -0,0 +1,180 @@
+package palettes
+
+import (
+	utils "app/lib/utils"
+	"fmt"
+	"image/color"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+const (
+	palettesCollection = "palettes"
+	classesCollection  = "landcover_classes"
+)
+
+// Store keeps the palettes and their landcover classes in memory. It is
+// loaded at startup and reloaded whenever a palette or class changes.
+type Store struct {
+	app core.App
+
+	mu        sync.RWMutex
+	palettes  map[string]*utils.LandcoverPalette
+	byName    map[string]string
+	defaultId string
+}
+
+func New(app core.App) *Store {
+	return &Store{
+		app:      app,
+		palettes: map[string]*utils.LandcoverPalette{},
+		byName:   map[string]string{},
+	}
+}
+
+// ParseHexColor parses #rrggbb or #rrggbbaa.
+func ParseHexColor(hex string) (color.NRGBA, error) {
+	value := strings.TrimPrefix(hex, "#")
+	if len(value) == 6 {
+		value += "ff"
+	}
+	if len(value) != 8 {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q", hex)
+	}
+	rgba, err := strconv.ParseUint(value, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q", hex)
+	}
+	return color.NRGBA{R: uint8(rgba >> 24), G: uint8(rgba >> 16), B: uint8(rgba >> 8), A: uint8(rgba)}, nil
+}
+
+// Load reads all palettes and classes from the database, replacing what the
+// store held before.
+func (s *Store) Load() error {
+	paletteRecords, err := s.app.Dao().FindRecordsByFilter(palettesCollection, "id != ''", "created", 0, 0)
+	if err != nil {
+		return err
+	}
+	classRecords, err := s.app.Dao().FindRecordsByFilter(classesCollection, "id != ''", "", 0, 0)
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(classRecords, func(i, j int) bool {
+		if classRecords[i].GetInt("order") != classRecords[j].GetInt("order") {
+			return classRecords[i].GetInt("order") < classRecords[j].GetInt("order")
+		}
+		return classRecords[i].GetInt("classId") < classRecords[j].GetInt("classId")
+	})
+
+	classes := map[string][]utils.Landcover{}
+	for _, record := range classRecords {
+		landcover, err := landcoverFromRecord(record)
+		if err != nil {
+			log.Printf("Skipping landcover class %s: %v", record.Id, err)
+			continue
+		}
+		paletteId := record.GetString("palette")
+		classes[paletteId] = append(classes[paletteId], landcover)
+	}
+
+	palettes := map[string]*utils.LandcoverPalette{}
+	byName := map[string]string{}
+	defaultId := ""
+	for _, record := range paletteRecords {
+		palette, err := utils.NewLandcoverPalette(classes[record.Id], utils.ColorDistance(record.GetString("distance")))
+		if err != nil {
+			log.Printf("Skipping palette %s: %v", record.Id, err)
+			continue
+		}
+		palettes[record.Id] = palette
+		byName[record.GetString("name")] = record.Id
+		if record.GetBool("default") && defaultId == "" {
+			defaultId = record.Id
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.palettes = palettes
+	s.byName = byName
+	s.defaultId = defaultId
+	return nil
+}
+
+func landcoverFromRecord(record *models.Record) (utils.Landcover, error) {
+	display, err := ParseHexColor(record.GetString("color"))
+	if err != nil {
+		return utils.Landcover{}, err
+	}
+	texture := color.NRGBA{}
+	if record.GetString("texture") != "" {
+		if texture, err = ParseHexColor(record.GetString("texture")); err != nil {
+			return utils.Landcover{}, err
+		}
+	}
+
+	return utils.Landcover{
+		Color:       display,
+		Texture:     texture,
+		Name:        record.GetString("name"),
+		ClassId:     record.GetInt("classId"),
+		Priority:    record.GetInt("priority"),
+		MinCoverage: record.GetFloat("minCoverage"),
+	}, nil
+}
+
+// Palette returns the palette with the given id or name.
+func (s *Store) Palette(idOrName string) (*utils.LandcoverPalette, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if id, ok := s.byName[idOrName]; ok {
+		idOrName = id
+	}
+	palette, ok := s.palettes[idOrName]
+	return palette, ok && len(palette.Classes) > 0
+}
+
+// Default returns the palette marked as default, falling back to the
+// built-in utils.Palette when there is none.
+func (s *Store) Default() *utils.LandcoverPalette {
+	s.mu.RLock()
+	defaultId := s.defaultId
+	s.mu.RUnlock()
+
+	if palette, ok := s.Palette(defaultId); ok {
+		return palette
+	}
+	return utils.Palette
+}
+
+// ForRecord returns the palette selected by a record's palette field, or the
+// default palette when it has none.
+func (s *Store) ForRecord(record *models.Record) *utils.LandcoverPalette {
+	if palette, ok := s.Palette(record.GetString("palette")); ok {
+		return palette
+	}
+	return s.Default()
+}
+
+// Watch reloads the store whenever a palette or landcover class is created,
+// updated or deleted, from the API or from code.
+func (s *Store) Watch() {
+	reload := func(e *core.ModelEvent) error {
+		if err := s.Load(); err != nil {
+			log.Printf("Failed to reload palettes: %v", err)
+		}
+		return nil
+	}
+
+	s.app.OnModelAfterCreate(palettesCollection, classesCollection).Add(reload)
+	s.app.OnModelAfterUpdate(palettesCollection, classesCollection).Add(reload)
+	s.app.OnModelAfterDelete(palettesCollection, classesCollection).Add(reload)
+}
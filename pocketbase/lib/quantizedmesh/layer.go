@@ -0,0 +1,85 @@
+package quantizedmesh
+
+import (
+	"app/lib/tilemath"
+	"math"
+)
+
+// GridSize is the number of vertices along each side of the grid a tile's
+// mesh is simplified from, matching Cesium's heightmap terrain quality.
+const GridSize = 65
+
+// Range is a rectangle of available tiles in the TMS scheme, inclusive.
+type Range struct {
+	StartX int `json:"startX"`
+	StartY int `json:"startY"`
+	EndX   int `json:"endX"`
+	EndY   int `json:"endY"`
+}
+
+// Layer is the layer.json Cesium reads before requesting any tile.
+type Layer struct {
+	TileJSON   string     `json:"tilejson"`
+	Name       string     `json:"name"`
+	Version    string     `json:"version"`
+	Format     string     `json:"format"`
+	Scheme     string     `json:"scheme"`
+	Projection string     `json:"projection"`
+	Tiles      []string   `json:"tiles"`
+	Extensions []string   `json:"extensions"`
+	MinZoom    int        `json:"minzoom"`
+	MaxZoom    int        `json:"maxzoom"`
+	Bounds     [4]float64 `json:"bounds"`
+	Available  [][]Range  `json:"available"`
+}
+
+// NewLayer describes a Web Mercator layer whose tiles covering bbox are
+// available from zoom 0 to maxZoom.
+func NewLayer(name string, bbox tilemath.BBox, maxZoom int) Layer {
+	layer := Layer{
+		TileJSON:   "2.1.0",
+		Name:       name,
+		Version:    "1.0.0",
+		Format:     "quantized-mesh-1.0",
+		Scheme:     "tms",
+		Projection: "EPSG:3857",
+		Tiles:      []string{"{z}/{x}/{y}.terrain?v={version}"},
+		Extensions: []string{"octvertexnormals", "watermask"},
+		MinZoom:    0,
+		MaxZoom:    maxZoom,
+		Bounds:     [4]float64(bbox),
+	}
+
+	for zoom := 0; zoom <= maxZoom; zoom++ {
+		last := (1 << zoom) - 1
+		clamp := func(value float64) int {
+			return max(0, min(last, int(math.Floor(value))))
+		}
+		// bboxes usually sit on tile edges, so nudge inwards to not make the
+		// neighbors available because of rounding
+		minX, maxX := clamp(tilemath.LonToTile(bbox.West(), zoom)+1e-6), clamp(tilemath.LonToTile(bbox.East(), zoom)-1e-6)
+		minY, maxY := clamp(tilemath.LatToTile(bbox.North(), zoom)+1e-6), clamp(tilemath.LatToTile(bbox.South(), zoom)-1e-6)
+		layer.Available = append(layer.Available, []Range{{
+			StartX: minX,
+			StartY: last - maxY,
+			EndX:   maxX,
+			EndY:   last - minY,
+		}})
+	}
+
+	return layer
+}
+
+// GeometricError returns the error in meters Cesium allows for a terrain
+// tile at zoom in a Web Mercator layer with a single root tile.
+func GeometricError(zoom int) float64 {
+	return EllipsoidA * 2 * math.Pi * 0.25 / GridSize / math.Pow(2, float64(zoom))
+}
+
+// MaxZoomForMetersPerPixel returns the zoom at which a GridSize grid per tile
+// matches the resolution of the source data at lat.
+func MaxZoomForMetersPerPixel(metersPerPixel float64, lat float64) int {
+	tileWidth := 2 * math.Pi * EllipsoidA * math.Cos(lat*math.Pi/180)
+	zoom := math.Ceil(math.Log2(tileWidth / (GridSize - 1) / metersPerPixel))
+	return int(math.Max(0, math.Min(22, zoom)))
+}
@@ -0,0 +1,308 @@
+package quantizedmesh
+
+import (
+	"app/lib/mesh"
+	"app/lib/tilemath"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// WGS84 ellipsoid radii in meters.
+const (
+	EllipsoidA = 6378137.0
+	EllipsoidB = 6356752.3142451793
+)
+
+const (
+	extensionOctVertexNormals = 1
+	extensionWaterMask        = 2
+
+	// quantized u, v and heights run from 0 to this value
+	quantizedMax = 32767
+)
+
+// Tile is a terrain tile ready to be encoded as quantized-mesh-1.0. The mesh
+// UVs place vertices within BBox, v = 0 being the northern edge. Cesium reads
+// v as running linearly in geodetic latitude whatever the tiling scheme, so
+// meshes sampled on a Web Mercator grid must be resampled with LonLat first.
+// Mesh normals are in the mesh's own east, up, south frame.
+type Tile struct {
+	Mesh *mesh.Mesh
+	BBox tilemath.BBox
+	// WaterMask is either one byte for the whole tile or 256x256 bytes from
+	// the north-west corner, 0 being land and 255 water. Nil leaves the tile
+	// without the extension.
+	WaterMask []byte
+}
+
+// ECEF converts geodetic coordinates in degrees and meters to earth-centered,
+// earth-fixed coordinates on the WGS84 ellipsoid.
+func ECEF(lon float64, lat float64, height float64) [3]float64 {
+	lambda, phi := lon*math.Pi/180, lat*math.Pi/180
+	e2 := 1 - (EllipsoidB*EllipsoidB)/(EllipsoidA*EllipsoidA)
+	n := EllipsoidA / math.Sqrt(1-e2*math.Sin(phi)*math.Sin(phi))
+	return [3]float64{
+		(n + height) * math.Cos(phi) * math.Cos(lambda),
+		(n + height) * math.Cos(phi) * math.Sin(lambda),
+		(n*(1-e2) + height) * math.Sin(phi),
+	}
+}
+
+// LonLat returns the coordinates of a point at fractional position (u, v)
+// in the tile, both running linearly in degrees as Cesium interpolates them.
+func (t Tile) LonLat(u float64, v float64) (float64, float64) {
+	return t.BBox.West() + u*(t.BBox.East()-t.BBox.West()), t.BBox.North() + v*(t.BBox.South()-t.BBox.North())
+}
+
+// Encode writes the tile as quantized-mesh-1.0, with the oct-encoded vertex
+// normals and water mask extensions when requested.
+func (t Tile) Encode(w io.Writer, normals bool, waterMask bool) error {
+	m := reorder(t.Mesh)
+	count := m.VertexCount()
+
+	minHeight, maxHeight := math.Inf(1), math.Inf(-1)
+	for i := 0; i < count; i++ {
+		minHeight = math.Min(minHeight, float64(m.Positions[i*3+1]))
+		maxHeight = math.Max(maxHeight, float64(m.Positions[i*3+1]))
+	}
+
+	positions := make([][3]float64, count)
+	lonLats := make([][2]float64, count)
+	boundsMin := [3]float64{math.Inf(1), math.Inf(1), math.Inf(1)}
+	boundsMax := [3]float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for i := range positions {
+		lon, lat := t.LonLat(float64(m.UVs[i*2]), float64(m.UVs[i*2+1]))
+		lonLats[i] = [2]float64{lon, lat}
+		positions[i] = ECEF(lon, lat, float64(m.Positions[i*3+1]))
+		for axis := 0; axis < 3; axis++ {
+			boundsMin[axis] = math.Min(boundsMin[axis], positions[i][axis])
+			boundsMax[axis] = math.Max(boundsMax[axis], positions[i][axis])
+		}
+	}
+
+	center := [3]float64{}
+	for axis := range center {
+		center[axis] = (boundsMin[axis] + boundsMax[axis]) / 2
+	}
+	radius := 0.0
+	for _, position := range positions {
+		radius = math.Max(radius, distance(center, position))
+	}
+
+	out := &bytes.Buffer{}
+	write := func(data any) {
+		binary.Write(out, binary.LittleEndian, data)
+	}
+
+	write(center)
+	write([]float32{float32(minHeight), float32(maxHeight)})
+	write(center)
+	write(radius)
+	write(horizonOcclusionPoint(center, positions))
+
+	// vertices are zig-zag delta encoded, u east, v north
+	write(uint32(count))
+	us, vs, heights := make([]uint16, count), make([]uint16, count), make([]uint16, count)
+	for i := 0; i < count; i++ {
+		us[i] = quantize(float64(m.UVs[i*2]), 0, 1)
+		vs[i] = quantize(1-float64(m.UVs[i*2+1]), 0, 1)
+		heights[i] = quantize(float64(m.Positions[i*3+1]), minHeight, maxHeight)
+	}
+	for _, values := range [][]uint16{us, vs, heights} {
+		write(zigZagDeltas(values))
+	}
+
+	wide := count > 65536
+	writeIndices := func(indices []uint32) {
+		if wide {
+			write(indices)
+			return
+		}
+		narrow := make([]uint16, len(indices))
+		for i, index := range indices {
+			narrow[i] = uint16(index)
+		}
+		write(narrow)
+	}
+
+	if wide {
+		for out.Len()%4 != 0 {
+			out.WriteByte(0)
+		}
+	}
+	write(uint32(len(m.Indices) / 3))
+	writeIndices(highWaterMark(m.Indices))
+
+	// west, south, east and north edge vertices
+	var edges [4][]uint32
+	for i := 0; i < count; i++ {
+		if us[i] == 0 {
+			edges[0] = append(edges[0], uint32(i))
+		}
+		if vs[i] == 0 {
+			edges[1] = append(edges[1], uint32(i))
+		}
+		if us[i] == quantizedMax {
+			edges[2] = append(edges[2], uint32(i))
+		}
+		if vs[i] == quantizedMax {
+			edges[3] = append(edges[3], uint32(i))
+		}
+	}
+	for _, edge := range edges {
+		write(uint32(len(edge)))
+		writeIndices(edge)
+	}
+
+	if normals {
+		encoded := make([]uint8, count*2)
+		for i := 0; i < count; i++ {
+			normal := ecefNormal(lonLats[i][0], lonLats[i][1], m.Normals[i*3:i*3+3])
+			encoded[i*2], encoded[i*2+1] = octEncode(normal)
+		}
+		write(uint8(extensionOctVertexNormals))
+		write(uint32(len(encoded)))
+		write(encoded)
+	}
+
+	if waterMask && t.WaterMask != nil {
+		write(uint8(extensionWaterMask))
+		write(uint32(len(t.WaterMask)))
+		write(t.WaterMask)
+	}
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// reorder returns the mesh with its vertices numbered in the order the
+// triangles first use them, which high water mark encoding relies on.
+func reorder(m *mesh.Mesh) *mesh.Mesh {
+	mapping := make([]int64, m.VertexCount())
+	for i := range mapping {
+		mapping[i] = -1
+	}
+
+	reordered := &mesh.Mesh{}
+	for _, index := range m.Indices {
+		if mapping[index] < 0 {
+			mapping[index] = int64(reordered.VertexCount())
+			reordered.Positions = append(reordered.Positions, m.Positions[index*3:index*3+3]...)
+			reordered.Normals = append(reordered.Normals, m.Normals[index*3:index*3+3]...)
+			reordered.UVs = append(reordered.UVs, m.UVs[index*2:index*2+2]...)
+		}
+		reordered.Indices = append(reordered.Indices, uint32(mapping[index]))
+	}
+	return reordered
+}
+
+func quantize(value float64, min float64, max float64) uint16 {
+	if max <= min {
+		return 0
+	}
+	return uint16(math.Round(math.Max(0, math.Min(1, (value-min)/(max-min))) * quantizedMax))
+}
+
+func zigZagDeltas(values []uint16) []uint16 {
+	encoded := make([]uint16, len(values))
+	previous := 0
+	for i, value := range values {
+		delta := int(value) - previous
+		encoded[i] = uint16((delta << 1) ^ (delta >> 31))
+		previous = int(value)
+	}
+	return encoded
+}
+
+func highWaterMark(indices []uint32) []uint32 {
+	encoded := make([]uint32, len(indices))
+	highest := uint32(0)
+	for i, index := range indices {
+		encoded[i] = highest - index
+		if index == highest {
+			highest++
+		}
+	}
+	return encoded
+}
+
+func distance(a [3]float64, b [3]float64) float64 {
+	return math.Sqrt((a[0]-b[0])*(a[0]-b[0]) + (a[1]-b[1])*(a[1]-b[1]) + (a[2]-b[2])*(a[2]-b[2]))
+}
+
+// horizonOcclusionPoint returns the point, in ellipsoid-scaled coordinates,
+// that is below the horizon exactly when the whole tile is. This follows
+// Cesium's EllipsoidalOccluder.
+func horizonOcclusionPoint(center [3]float64, positions [][3]float64) [3]float64 {
+	scale := func(p [3]float64) [3]float64 {
+		return [3]float64{p[0] / EllipsoidA, p[1] / EllipsoidA, p[2] / EllipsoidB}
+	}
+	normalize := func(p [3]float64) [3]float64 {
+		length := math.Sqrt(p[0]*p[0] + p[1]*p[1] + p[2]*p[2])
+		return [3]float64{p[0] / length, p[1] / length, p[2] / length}
+	}
+
+	direction := normalize(scale(center))
+	maxMagnitude := 0.0
+	for _, position := range positions {
+		scaled := scale(position)
+		magnitudeSquared := scaled[0]*scaled[0] + scaled[1]*scaled[1] + scaled[2]*scaled[2]
+		// points below the ellipsoid count as on it
+		magnitudeSquared = math.Max(1, magnitudeSquared)
+		magnitude := math.Sqrt(magnitudeSquared)
+		toPoint := normalize(scaled)
+
+		cosAlpha := direction[0]*toPoint[0] + direction[1]*toPoint[1] + direction[2]*toPoint[2]
+		cross := [3]float64{
+			direction[1]*toPoint[2] - direction[2]*toPoint[1],
+			direction[2]*toPoint[0] - direction[0]*toPoint[2],
+			direction[0]*toPoint[1] - direction[1]*toPoint[0],
+		}
+		sinAlpha := math.Sqrt(cross[0]*cross[0] + cross[1]*cross[1] + cross[2]*cross[2])
+		cosBeta := 1 / magnitude
+		sinBeta := math.Sqrt(magnitudeSquared-1) * cosBeta
+
+		maxMagnitude = math.Max(maxMagnitude, 1/(cosAlpha*cosBeta-sinAlpha*sinBeta))
+	}
+
+	return [3]float64{direction[0] * maxMagnitude, direction[1] * maxMagnitude, direction[2] * maxMagnitude}
+}
+
+// ecefNormal turns a normal from a mesh's east, up, south frame at lon, lat
+// into earth-centered coordinates.
+func ecefNormal(lon float64, lat float64, normal []float32) [3]float64 {
+	lambda, phi := lon*math.Pi/180, lat*math.Pi/180
+	east := [3]float64{-math.Sin(lambda), math.Cos(lambda), 0}
+	north := [3]float64{-math.Sin(phi) * math.Cos(lambda), -math.Sin(phi) * math.Sin(lambda), math.Cos(phi)}
+	up := [3]float64{math.Cos(phi) * math.Cos(lambda), math.Cos(phi) * math.Sin(lambda), math.Sin(phi)}
+
+	e, u, s := float64(normal[0]), float64(normal[1]), float64(normal[2])
+	result := [3]float64{}
+	for axis := range result {
+		result[axis] = e*east[axis] + u*up[axis] - s*north[axis]
+	}
+	return result
+}
+
+// octEncode packs a unit vector into two bytes with octahedral encoding.
+func octEncode(normal [3]float64) (uint8, uint8) {
+	signNotZero := func(value float64) float64 {
+		if value < 0 {
+			return -1
+		}
+		return 1
+	}
+
+	sum := math.Abs(normal[0]) + math.Abs(normal[1]) + math.Abs(normal[2])
+	x, y := normal[0]/sum, normal[1]/sum
+	if normal[2] < 0 {
+		x, y = (1-math.Abs(y))*signNotZero(x), (1-math.Abs(x))*signNotZero(y)
+	}
+
+	toByte := func(value float64) uint8 {
+		return uint8(math.Round((math.Max(-1, math.Min(1, value))*0.5 + 0.5) * 255))
+	}
+	return toByte(x), toByte(y)
+}
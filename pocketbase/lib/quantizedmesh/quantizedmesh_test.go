@@ -0,0 +1,37 @@
+package quantizedmesh
+
+import (
+	"app/lib/tilemath"
+	"math"
+	"testing"
+)
+
+// Cesium interpolates v linearly in latitude, so the middle row of a Web
+// Mercator tile sits at the mean of its edge latitudes, not at the Mercator
+// midpoint.
+func TestLonLatIsLinearInDegrees(t *testing.T) {
+	bounds := tilemath.Tile{X: 1, Y: 0, Z: 1}.Bounds()
+	tile := Tile{BBox: bounds}
+
+	tests := []struct {
+		u, v     float64
+		lon, lat float64
+	}{
+		{0, 0, bounds.West(), bounds.North()},
+		{1, 1, bounds.East(), bounds.South()},
+		{0.5, 0.5, (bounds.West() + bounds.East()) / 2, (bounds.North() + bounds.South()) / 2},
+		{0.25, 0.75, bounds.West() + 0.25*(bounds.East()-bounds.West()), bounds.North() + 0.75*(bounds.South()-bounds.North())},
+	}
+	for _, test := range tests {
+		lon, lat := tile.LonLat(test.u, test.v)
+		if math.Abs(lon-test.lon) > 1e-9 || math.Abs(lat-test.lat) > 1e-9 {
+			t.Errorf("LonLat(%v, %v) = %v, %v, want %v, %v", test.u, test.v, lon, lat, test.lon, test.lat)
+		}
+	}
+
+	// the Mercator midpoint of this tile is at about 66.5°, far from 42.5°
+	_, lat := tile.LonLat(0.5, 0.5)
+	if mercator := tilemath.TileToLat(0.25, 0); math.Abs(lat-mercator) < 10 {
+		t.Errorf("middle latitude %v is the Web Mercator midpoint %v", lat, mercator)
+	}
+}
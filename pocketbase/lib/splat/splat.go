@@ -0,0 +1,153 @@
+package splat
+
+import (
+	"image"
+	"math"
+)
+
+// ChannelsPerImage is how many class weights one RGBA splat map holds.
+const ChannelsPerImage = 4
+
+// ChannelNames are the channels of a splat map in order.
+var ChannelNames = [ChannelsPerImage]string{"r", "g", "b", "a"}
+
+// Images turns a map of class indices, width x height from the north-west
+// corner, into splat maps of ChannelsPerImage classes each, class i landing
+// in image i/4, channel i%4. Every class starts as a one-hot weight layer
+// that is blurred with a Gaussian of sigma pixels to blend the edges between
+// classes, then divided by the blurred weight of all classes.
+//
+// The 8-bit weights of a pixel sum to exactly 255 across all images;
+// rounding leftovers go to the heaviest class. Indices outside [0, classes)
+// get no weight, so unclassified pixels out of the blur's reach of any class
+// are 0 in every channel.
+//
+// Layers are built one class at a time: besides the images, which take a
+// byte per class, memory doesn't grow with the number of classes.
+func Images(indices []int, width int, height int, classes int, sigma float64) []*image.NRGBA {
+	count := (classes + ChannelsPerImage - 1) / ChannelsPerImage
+	images := make([]*image.NRGBA, count)
+	for i := range images {
+		images[i] = image.NewNRGBA(image.Rect(0, 0, width, height))
+	}
+
+	// the blur is linear, so the blurred one-hot layers of all classes sum
+	// to the blurred mask of classified pixels
+	total := make([]float32, width*height)
+	for i, class := range indices {
+		if class >= 0 && class < classes {
+			total[i] = 1
+		}
+	}
+	blur(total, width, height, sigma)
+
+	layer := make([]float32, width*height)
+	for class := 0; class < classes; class++ {
+		for i, index := range indices {
+			layer[i] = 0
+			if index == class {
+				layer[i] = 1
+			}
+		}
+		blur(layer, width, height, sigma)
+
+		pix, channel := images[class/ChannelsPerImage].Pix, class%ChannelsPerImage
+		for i, weight := range layer {
+			if classified(total[i]) {
+				pix[i*4+channel] = uint8(max(0, min(255, math.Round(float64(weight/total[i])*255))))
+			}
+		}
+	}
+
+	for i, weight := range total {
+		if !classified(weight) {
+			continue
+		}
+		sum, heaviest := 0, 0
+		for class := 0; class < classes; class++ {
+			value := images[class/ChannelsPerImage].Pix[i*4+class%ChannelsPerImage]
+			sum += int(value)
+			if value > images[heaviest/ChannelsPerImage].Pix[i*4+heaviest%ChannelsPerImage] {
+				heaviest = class
+			}
+		}
+		value := &images[heaviest/ChannelsPerImage].Pix[i*4+heaviest%ChannelsPerImage]
+		*value = uint8(max(0, min(255, int(*value)+255-sum)))
+	}
+
+	return images
+}
+
+// classified reports whether a pixel has any class weight. The running sums
+// of the blur leave rounding noise where the true weight is 0.
+func classified(total float32) bool {
+	return total > 1e-6
+}
+
+// blur convolves a width x height layer in place with a Gaussian of sigma
+// pixels, clamping at the edges. The Gaussian is approximated by three box
+// blurs of running sums, so the cost doesn't grow with sigma. Box widths are
+// whole pixels, which makes sigmas below 2 up to 20% narrower.
+func blur(layer []float32, width int, height int, sigma float64) {
+	if sigma <= 0 {
+		return
+	}
+
+	// separable: rows first, then columns
+	line := make([]float32, max(width, height))
+	for _, radius := range boxRadii(sigma, 3) {
+		for y := 0; y < height; y++ {
+			boxBlur(layer[y*width:], layer[y*width:], 1, width, radius, line)
+		}
+		for x := 0; x < width; x++ {
+			boxBlur(layer[x:], layer[x:], width, height, radius, line)
+		}
+	}
+}
+
+// boxRadii returns the radii of n successive box blurs whose combined
+// variance comes closest to a Gaussian of sigma, as in Kovesi's "Fast Almost-
+// Gaussian Filtering".
+func boxRadii(sigma float64, n int) []int {
+	ideal := math.Sqrt(12*sigma*sigma/float64(n) + 1)
+	lower := int(math.Floor(ideal))
+	if lower%2 == 0 {
+		lower--
+	}
+	upper := lower + 2
+	// how many of the boxes take the lower width
+	m := int(math.Round((12*sigma*sigma - float64(n*lower*lower+4*n*lower+3*n)) / float64(-4*lower-4)))
+
+	radii := make([]int, n)
+	for i := range radii {
+		if i < m {
+			radii[i] = (lower - 1) / 2
+		} else {
+			radii[i] = (upper - 1) / 2
+		}
+	}
+	return radii
+}
+
+// boxBlur averages the n values of src at the given stride over a window of
+// radius values on each side, clamping at the ends, into dst at the same
+// stride. line is scratch space for at least n values; src is copied into it
+// first, so src and dst may be the same.
+func boxBlur(src []float32, dst []float32, stride int, n int, radius int, line []float32) {
+	for i := 0; i < n; i++ {
+		line[i] = src[i*stride]
+	}
+	at := func(i int) float64 {
+		return float64(line[max(0, min(n-1, i))])
+	}
+
+	sum := 0.0
+	for i := -radius; i <= radius; i++ {
+		sum += at(i)
+	}
+	scale := 1 / float64(2*radius+1)
+	for i := 0; i < n; i++ {
+		dst[i*stride] = float32(sum * scale)
+		sum += at(i+radius+1) - at(i-radius)
+	}
+}
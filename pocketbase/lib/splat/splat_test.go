@@ -0,0 +1,165 @@
+package splat
+
+import (
+	"image"
+	"math"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// impulse returns a size x size layer of zeros with a single 1 at the center.
+func impulse(size int) []float32 {
+	layer := make([]float32, size*size)
+	layer[size/2*size+size/2] = 1
+	return layer
+}
+
+func TestBlurApproximatesGaussian(t *testing.T) {
+	for _, sigma := range []float64{2, 3, 7.5, 20, 50} {
+		size := int(12*sigma) | 1
+		layer := impulse(size)
+		blur(layer, size, size, sigma)
+
+		// the spread of the impulse along a row through its center, once
+		// the column blur's share of the weight is divided out
+		center := size / 2
+		row := layer[center*size : (center+1)*size]
+		mass, variance := 0.0, 0.0
+		for x, value := range row {
+			d := float64(x - center)
+			mass += float64(value)
+			variance += d * d * float64(value)
+		}
+		variance /= mass
+		if math.Abs(math.Sqrt(variance)-sigma)/sigma > 0.1 {
+			t.Errorf("sigma %v: spread %v", sigma, math.Sqrt(variance))
+		}
+
+		// symmetric about the impulse, peaking on it
+		for d := 1; d < center; d++ {
+			if math.Abs(float64(row[center-d]-row[center+d])) > 1e-6 {
+				t.Fatalf("sigma %v: asymmetric at ±%d: %v, %v", sigma, d, row[center-d], row[center+d])
+			}
+			if row[center+d] > row[center+d-1] {
+				t.Fatalf("sigma %v: weight grows away from the impulse at %d", sigma, d)
+			}
+		}
+	}
+}
+
+// Blur used to convolve with the whole kernel, so its cost grew with sigma.
+func TestBlurCostDoesNotGrowWithSigma(t *testing.T) {
+	timeBlur := func(sigma float64) time.Duration {
+		layer := impulse(512)
+		start := time.Now()
+		blur(layer, 512, 512, sigma)
+		return time.Since(start)
+	}
+	timeBlur(1)
+	small, large := timeBlur(1), timeBlur(50)
+	if large > 4*small+20*time.Millisecond {
+		t.Errorf("blur 50 took %v, blur 1 %v", large, small)
+	}
+}
+
+// channelSum adds up the channels of all images at a pixel.
+func channelSum(images []*image.NRGBA, x int, y int) int {
+	sum := 0
+	for _, img := range images {
+		pix := img.Pix[img.PixOffset(x, y):]
+		sum += int(pix[0]) + int(pix[1]) + int(pix[2]) + int(pix[3])
+	}
+	return sum
+}
+
+func TestImagesSumTo255(t *testing.T) {
+	const size = 64
+	indices := make([]int, size*size)
+	for i := range indices {
+		indices[i] = (i%size/8 + i/size/8) % 9
+	}
+	indices[5] = -1
+
+	for _, sigma := range []float64{0, 1.5, 4} {
+		images := Images(indices, size, size, 9, sigma)
+		if len(images) != 3 {
+			t.Fatalf("%d images, want 3", len(images))
+		}
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				if sigma == 0 && x == 5 && y == 0 {
+					continue
+				}
+				if sum := channelSum(images, x, y); sum != 255 {
+					t.Fatalf("blur %v: pixel %d,%d sums to %d", sigma, x, y, sum)
+				}
+			}
+		}
+	}
+}
+
+func TestImagesPlaceClassesInChannels(t *testing.T) {
+	indices := []int{0, 1, 2, 3, 4, -1}
+	images := Images(indices, 6, 1, 5, 0)
+	if len(images) != 2 {
+		t.Fatalf("%d images, want 2", len(images))
+	}
+	for class := 0; class < 5; class++ {
+		img := images[class/ChannelsPerImage]
+		if got := img.Pix[img.PixOffset(class, 0)+class%ChannelsPerImage]; got != 255 {
+			t.Errorf("class %d has weight %d in image %d channel %s, want 255", class, got, class/ChannelsPerImage, ChannelNames[class%ChannelsPerImage])
+		}
+	}
+}
+
+func TestImagesLeaveUnclassifiedPixelsEmpty(t *testing.T) {
+	const size = 64
+	// class 0 in the left quarter, everything else unclassified
+	indices := make([]int, size*size)
+	for i := range indices {
+		indices[i] = -1
+		if i%size < size/4 {
+			indices[i] = 0
+		}
+	}
+
+	images := Images(indices, size, size, 2, 2)
+	reach := 0
+	for _, radius := range boxRadii(2, 3) {
+		reach += radius
+	}
+	near, far := size/4-1+reach, size/4+reach
+	for y := 0; y < size; y++ {
+		if sum := channelSum(images, near, y); sum != 255 {
+			t.Errorf("pixel %d,%d in reach of class 0 sums to %d, want 255", near, y, sum)
+		}
+		if sum := channelSum(images, far, y); sum != 0 {
+			t.Errorf("pixel %d,%d out of reach sums to %d, want 0", far, y, sum)
+		}
+	}
+}
+
+// Every class used to be held as a float32 layer at once, 600MB for nine
+// classes at 4097 x 4097.
+func TestImagesMemoryDoesNotGrowWithClasses(t *testing.T) {
+	const size = 512
+	indices := make([]int, size*size)
+	for i := range indices {
+		indices[i] = i % 32
+	}
+
+	allocated := func(classes int) uint64 {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		Images(indices, size, size, classes, 3)
+		runtime.ReadMemStats(&after)
+		return after.TotalAlloc - before.TotalAlloc
+	}
+	few, many := allocated(4), allocated(32)
+	// the images take a byte per class and pixel, layers would take four
+	if extra := many - few; extra > 2*28*size*size {
+		t.Errorf("32 classes allocate %d bytes more than 4", extra)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build linux
+
+package tilecache
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// accessTime returns when a file was last read, or its modification time
+// where the platform doesn't say.
+func accessTime(info fs.FileInfo) time.Time {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(stat.Atim.Unix())
+	}
+	return info.ModTime()
+}
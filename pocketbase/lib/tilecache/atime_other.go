@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package tilecache
+
+import (
+	"io/fs"
+	"time"
+)
+
+// accessTime returns the modification time, the only time every platform
+// keeps; tiles then count as last read when they were written.
+func accessTime(info fs.FileInfo) time.Time {
+	return info.ModTime()
+}
@@ -0,0 +1,56 @@
+package tilecache
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// NewCommand returns the "cache" command with stats and purge subcommands
+// for operating the tile cache from the PocketBase root command.
+func NewCommand(cache *Cache) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect and purge the downloaded tile cache",
+	}
+
+	command.AddCommand(&cobra.Command{
+		Use:   "stats",
+		Short: "Print tile cache usage",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stats, err := cache.Stats()
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Directory: %s\n", cache.Dir)
+			fmt.Printf("Entries:   %d (%d expired)\n", stats.Entries, stats.Expired)
+			fmt.Printf("Size:      %.1f MB of %.1f MB\n", float64(stats.Size)/megabyte, float64(stats.MaxSize)/megabyte)
+			fmt.Printf("TTL:       %s\n", stats.TTL)
+			if !stats.Oldest.IsZero() {
+				fmt.Printf("Oldest:    %s\n", stats.Oldest.Format("2006-01-02 15:04:05"))
+			}
+			return nil
+		},
+	})
+
+	var expiredOnly bool
+	purge := &cobra.Command{
+		Use:   "purge",
+		Short: "Remove cached tiles",
+		Long:  "Remove cached tiles. A running server rescans the cache the next time it finds a tile missing.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			removed, err := cache.Purge(expiredOnly)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Removed %d cached tiles\n", removed)
+			return nil
+		},
+	}
+	purge.Flags().BoolVar(&expiredOnly, "expired", false, "only remove tiles older than the TTL")
+	command.AddCommand(purge)
+
+	return command
+}
@@ -0,0 +1,290 @@
+package tilecache
+
+import (
+	utils "app/lib/utils"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache stores downloaded tiles on disk. It is keyed, not content-addressed:
+// files are named by the sha256 of their key (e.g.
+// "mapbox/mapbox.satellite/12/2200/1200"), expire after TTL and the least
+// recently used tiles are evicted once the cache grows beyond MaxSize bytes.
+// A file's modification time is when it was written and its access time when
+// it was last read, so both survive a restart.
+//
+// The index of the files is kept in memory. When a tile turns out to be gone
+// from disk, e.g. after a "cache purge" from another process, the index is
+// stale and the directory is scanned again on the next call.
+type Cache struct {
+	Dir     string
+	TTL     time.Duration
+	MaxSize int64
+
+	mu     sync.Mutex
+	loaded bool
+	index  map[string]*entry
+	size   int64
+}
+
+type entry struct {
+	path       string
+	size       int64
+	written    time.Time
+	lastAccess time.Time
+}
+
+// megabyte is the unit of TILE_CACHE_MAX_MB and of the cache stats.
+const megabyte = 1024 * 1024
+
+type Stats struct {
+	Entries int           `json:"entries"`
+	Size    int64         `json:"size"`
+	MaxSize int64         `json:"maxSize"`
+	Expired int           `json:"expired"`
+	TTL     time.Duration `json:"ttl"`
+	Oldest  time.Time     `json:"oldest"`
+}
+
+func New(dir string, ttl time.Duration, maxSize int64) *Cache {
+	return &Cache{
+		Dir:     dir,
+		TTL:     ttl,
+		MaxSize: maxSize,
+	}
+}
+
+// NewFromEnv creates a cache in dir configured by TILE_CACHE_TTL (e.g.
+// "720h") and TILE_CACHE_MAX_MB.
+func NewFromEnv(dir string) *Cache {
+	ttl := utils.DurationFromEnv("TILE_CACHE_TTL", 30*24*time.Hour)
+	maxMB := utils.IntFromEnv("TILE_CACHE_MAX_MB", 1024)
+
+	return New(dir, ttl, int64(maxMB)*megabyte)
+}
+
+func (c *Cache) pathForKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(c.Dir, name[:2], name)
+}
+
+// load builds the in-memory index from the files on disk.
+func (c *Cache) load() error {
+	if c.loaded {
+		return nil
+	}
+	c.index = map[string]*entry{}
+	c.size = 0
+
+	err := filepath.WalkDir(c.Dir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".tmp-") {
+			// left behind by an interrupted Put
+			return os.Remove(filePath)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		lastAccess := accessTime(info)
+		if lastAccess.Before(info.ModTime()) {
+			lastAccess = info.ModTime()
+		}
+		c.index[filePath] = &entry{
+			path:       filePath,
+			size:       info.Size(),
+			written:    info.ModTime(),
+			lastAccess: lastAccess,
+		}
+		c.size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.loaded = true
+	return nil
+}
+
+func (c *Cache) expired(e *entry, now time.Time) bool {
+	return c.TTL > 0 && now.Sub(e.written) > c.TTL
+}
+
+func (c *Cache) remove(e *entry) {
+	if err := os.Remove(e.path); os.IsNotExist(err) {
+		c.loaded = false
+	}
+	delete(c.index, e.path)
+	c.size -= e.size
+}
+
+// Get returns the cached tile for key, if present and not expired.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.load(); err != nil {
+		return nil, false
+	}
+
+	e, ok := c.index[c.pathForKey(key)]
+	if !ok {
+		return nil, false
+	}
+	now := time.Now()
+	if c.expired(e, now) {
+		c.remove(e)
+		return nil, false
+	}
+
+	data, err := os.ReadFile(e.path)
+	if err != nil {
+		c.remove(e)
+		return nil, false
+	}
+	// filesystems mounted noatime don't record reads themselves, and the
+	// modification time has to stay the write time for the TTL
+	os.Chtimes(e.path, now, e.written)
+	e.lastAccess = now
+
+	return data, true
+}
+
+// Put stores data under key and evicts least recently used tiles until the
+// cache fits in MaxSize.
+func (c *Cache) Put(key string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.load(); err != nil {
+		return err
+	}
+
+	filePath := c.pathForKey(key)
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		return err
+	}
+
+	// write to a temporary file first so readers never see a partial tile
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if previous, ok := c.index[filePath]; ok {
+		c.size -= previous.size
+	}
+	now := time.Now()
+	c.index[filePath] = &entry{
+		path:       filePath,
+		size:       int64(len(data)),
+		written:    now,
+		lastAccess: now,
+	}
+	c.size += int64(len(data))
+
+	c.evict()
+
+	return nil
+}
+
+func (c *Cache) evict() {
+	if c.MaxSize <= 0 || c.size <= c.MaxSize {
+		return
+	}
+
+	entries := make([]*entry, 0, len(c.index))
+	for _, e := range c.index {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastAccess.Before(entries[j].lastAccess)
+	})
+
+	for _, e := range entries {
+		if c.size <= c.MaxSize {
+			break
+		}
+		c.remove(e)
+	}
+}
+
+// Purge removes every cached tile, or only the expired ones if expiredOnly
+// is set, and returns how many tiles were removed.
+func (c *Cache) Purge(expiredOnly bool) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.load(); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	removed := 0
+	for _, e := range c.index {
+		if expiredOnly && !c.expired(e, now) {
+			continue
+		}
+		c.remove(e)
+		removed++
+	}
+
+	return removed, nil
+}
+
+func (c *Cache) Stats() (Stats, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.load(); err != nil {
+		return Stats{}, err
+	}
+
+	now := time.Now()
+	stats := Stats{
+		Entries: len(c.index),
+		Size:    c.size,
+		MaxSize: c.MaxSize,
+		TTL:     c.TTL,
+	}
+	for _, e := range c.index {
+		if c.expired(e, now) {
+			stats.Expired++
+		}
+		if stats.Oldest.IsZero() || e.written.Before(stats.Oldest) {
+			stats.Oldest = e.written
+		}
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,117 @@
+package tilecache
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestGetAndPut(t *testing.T) {
+	cache := New(t.TempDir(), time.Hour, 0)
+	if _, ok := cache.Get("a"); ok {
+		t.Fatal("empty cache returned a tile")
+	}
+	if err := cache.Put("a", []byte("tile a")); err != nil {
+		t.Fatal(err)
+	}
+	data, ok := cache.Get("a")
+	if !ok || !bytes.Equal(data, []byte("tile a")) {
+		t.Errorf("Get(a) = %q, %v", data, ok)
+	}
+}
+
+// age pretends a tile was written and last read d ago.
+func age(t *testing.T, cache *Cache, key string, d time.Duration) {
+	t.Helper()
+	then := time.Now().Add(-d)
+	if err := os.Chtimes(cache.pathForKey(key), then, then); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLeastRecentlyUsedSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	cache := New(dir, 0, 20)
+	for _, key := range []string{"a", "b"} {
+		if err := cache.Put(key, bytes.Repeat([]byte{1}, 8)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	age(t, cache, "a", 2*time.Hour)
+	age(t, cache, "b", time.Hour)
+
+	// reading a makes b the least recently used, even after a restart
+	restarted := New(dir, 0, 20)
+	if _, ok := restarted.Get("a"); !ok {
+		t.Fatal("a is missing")
+	}
+	restarted = New(dir, 0, 20)
+	if err := restarted.Put("c", bytes.Repeat([]byte{1}, 8)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := restarted.Get("b"); ok {
+		t.Error("b survived, want it evicted as the least recently used")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := restarted.Get(key); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+}
+
+func TestReadsDontExtendTTL(t *testing.T) {
+	dir := t.TempDir()
+	cache := New(dir, time.Hour, 0)
+	if err := cache.Put("a", []byte("tile a")); err != nil {
+		t.Fatal(err)
+	}
+	age(t, cache, "a", 59*time.Minute)
+	cache = New(dir, time.Hour, 0)
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("a expired early")
+	}
+
+	info, err := os.Stat(cache.pathForKey("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if since := time.Since(info.ModTime()); since < 58*time.Minute {
+		t.Errorf("reading a moved its write time to %v ago", since)
+	}
+
+	stats, err := New(dir, 30*time.Minute, 0).Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Expired != 1 {
+		t.Errorf("%d expired after a restart with a shorter TTL, want 1", stats.Expired)
+	}
+}
+
+// A "cache purge" from the command line runs in its own process, the
+// server's index only notices once it finds a tile missing.
+func TestRescansAfterPurgeFromAnotherProcess(t *testing.T) {
+	dir := t.TempDir()
+	server := New(dir, 0, 20)
+	for _, key := range []string{"a", "b"} {
+		if err := server.Put(key, bytes.Repeat([]byte{1}, 8)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if removed, err := New(dir, 0, 20).Purge(false); err != nil || removed != 2 {
+		t.Fatalf("Purge() = %d, %v, want 2", removed, err)
+	}
+
+	if _, ok := server.Get("a"); ok {
+		t.Fatal("purged tile a was returned")
+	}
+	stats, err := server.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Entries != 0 || stats.Size != 0 {
+		t.Errorf("stats after purge: %d entries of %d bytes, want none", stats.Entries, stats.Size)
+	}
+}
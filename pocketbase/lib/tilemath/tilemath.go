@@ -0,0 +1,173 @@
+package tilemath
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// MaxLatitude is the latitude where Web Mercator tiles end.
+var MaxLatitude = math.Atan(math.Sinh(math.Pi)) * 180 / math.Pi
+
+// BBox is a bounding box in degrees ordered as [west, south, east, north],
+// the same order as GeoJSON and @mapbox/tilebelt.
+type BBox [4]float64
+
+func (b BBox) West() float64  { return b[0] }
+func (b BBox) South() float64 { return b[1] }
+func (b BBox) East() float64  { return b[2] }
+func (b BBox) North() float64 { return b[3] }
+
+func (b BBox) Center() (float64, float64) {
+	return (b[0] + b[2]) / 2, (b[1] + b[3]) / 2
+}
+
+// Corners returns the north-west, north-east, south-east and south-west
+// corners of the box.
+func (b BBox) Corners() [4][2]float64 {
+	return [4][2]float64{
+		{b.West(), b.North()},
+		{b.East(), b.North()},
+		{b.East(), b.South()},
+		{b.West(), b.South()},
+	}
+}
+
+// Equal reports whether every edge of the boxes is within tolerance degrees.
+func (b BBox) Equal(other BBox, tolerance float64) bool {
+	for i := range b {
+		if math.Abs(b[i]-other[i]) > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// Tile is a slippy-map tile.
+type Tile struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	Z int `json:"z"`
+}
+
+func (t Tile) String() string {
+	return fmt.Sprintf("%d/%d/%d", t.Z, t.X, t.Y)
+}
+
+func (t Tile) Valid() bool {
+	if t.Z < 0 || t.Z > 30 {
+		return false
+	}
+	n := 1 << t.Z
+	return t.X >= 0 && t.X < n && t.Y >= 0 && t.Y < n
+}
+
+// TileToLon returns the longitude of the west edge of tile column x.
+func TileToLon(x float64, zoom int) float64 {
+	return x/math.Exp2(float64(zoom))*360 - 180
+}
+
+// TileToLat returns the latitude of the north edge of tile row y.
+func TileToLat(y float64, zoom int) float64 {
+	n := math.Pi - 2*math.Pi*y/math.Exp2(float64(zoom))
+	return math.Atan(math.Sinh(n)) * 180 / math.Pi
+}
+
+// LonToTile returns the fractional tile column containing lon.
+func LonToTile(lon float64, zoom int) float64 {
+	return (lon + 180) / 360 * math.Exp2(float64(zoom))
+}
+
+// LatToTile returns the fractional tile row containing lat.
+func LatToTile(lat float64, zoom int) float64 {
+	lat = math.Max(-MaxLatitude, math.Min(MaxLatitude, lat))
+	rad := lat * math.Pi / 180
+	return (1 - math.Log(math.Tan(rad)+1/math.Cos(rad))/math.Pi) / 2 * math.Exp2(float64(zoom))
+}
+
+// TileForLonLat returns the tile at zoom containing the point.
+func TileForLonLat(lon float64, lat float64, zoom int) Tile {
+	n := 1<<zoom - 1
+	x := int(math.Floor(LonToTile(lon, zoom)))
+	y := int(math.Floor(LatToTile(lat, zoom)))
+	return Tile{
+		X: max(0, min(n, x)),
+		Y: max(0, min(n, y)),
+		Z: zoom,
+	}
+}
+
+func (t Tile) Bounds() BBox {
+	return BBox{
+		TileToLon(float64(t.X), t.Z),
+		TileToLat(float64(t.Y+1), t.Z),
+		TileToLon(float64(t.X+1), t.Z),
+		TileToLat(float64(t.Y), t.Z),
+	}
+}
+
+// Center returns the lon/lat of the center of the tile in Mercator space,
+// which is slightly north of the center of its bbox.
+func (t Tile) Center() (float64, float64) {
+	return TileToLon(float64(t.X)+0.5, t.Z), TileToLat(float64(t.Y)+0.5, t.Z)
+}
+
+// Corners returns the lon/lat of the north-west, north-east, south-east and
+// south-west corners of the tile.
+func (t Tile) Corners() [4][2]float64 {
+	return t.Bounds().Corners()
+}
+
+func (t Tile) Quadkey() string {
+	var quadkey strings.Builder
+	for z := t.Z; z > 0; z-- {
+		digit := '0'
+		mask := 1 << (z - 1)
+		if t.X&mask != 0 {
+			digit++
+		}
+		if t.Y&mask != 0 {
+			digit += 2
+		}
+		quadkey.WriteRune(digit)
+	}
+	return quadkey.String()
+}
+
+func TileFromQuadkey(quadkey string) (Tile, error) {
+	tile := Tile{Z: len(quadkey)}
+	for i, digit := range quadkey {
+		mask := 1 << (tile.Z - i - 1)
+		switch digit {
+		case '0':
+		case '1':
+			tile.X |= mask
+		case '2':
+			tile.Y |= mask
+		case '3':
+			tile.X |= mask
+			tile.Y |= mask
+		default:
+			return Tile{}, fmt.Errorf("invalid quadkey digit %q in %q", digit, quadkey)
+		}
+	}
+	return tile, nil
+}
+
+func (t Tile) Parent() Tile {
+	if t.Z == 0 {
+		return t
+	}
+	return Tile{X: t.X >> 1, Y: t.Y >> 1, Z: t.Z - 1}
+}
+
+// Children returns the four tiles one zoom level down, in quadkey order.
+func (t Tile) Children() [4]Tile {
+	x, y, z := t.X*2, t.Y*2, t.Z+1
+	return [4]Tile{
+		{X: x, Y: y, Z: z},
+		{X: x + 1, Y: y, Z: z},
+		{X: x, Y: y + 1, Z: z},
+		{X: x + 1, Y: y + 1, Z: z},
+	}
+}
@@ -0,0 +1,141 @@
+package tilemath
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBoundsRoundTrip(t *testing.T) {
+	tests := []Tile{
+		{X: 0, Y: 0, Z: 0},
+		{X: 292, Y: 391, Z: 10},
+		{X: 2200, Y: 1200, Z: 12},
+		// against the ±85.0511° limit and the antimeridian
+		{X: 0, Y: 0, Z: 8},
+		{X: 255, Y: 255, Z: 8},
+		{X: 0, Y: 255, Z: 8},
+		{X: 255, Y: 0, Z: 8},
+		{X: 1<<20 - 1, Y: 1<<20 - 1, Z: 20},
+	}
+	for _, tile := range tests {
+		bounds := tile.Bounds()
+		if bounds.West() >= bounds.East() || bounds.South() >= bounds.North() {
+			t.Errorf("%s: bounds %v are inverted", tile, bounds)
+		}
+		lon, lat := bounds.Center()
+		if got := TileForLonLat(lon, lat, tile.Z); got != tile {
+			t.Errorf("%s: center of %v is in tile %s", tile, bounds, got)
+		}
+
+		// the edges map back to whole tile numbers
+		edges := [4]float64{
+			LonToTile(bounds.West(), tile.Z),
+			LatToTile(bounds.South(), tile.Z),
+			LonToTile(bounds.East(), tile.Z),
+			LatToTile(bounds.North(), tile.Z),
+		}
+		want := [4]float64{float64(tile.X), float64(tile.Y + 1), float64(tile.X + 1), float64(tile.Y)}
+		for i := range edges {
+			if math.Abs(edges[i]-want[i]) > 1e-6 {
+				t.Errorf("%s: edge %d maps back to %v, want %v", tile, i, edges[i], want[i])
+			}
+		}
+	}
+}
+
+func TestWorldTile(t *testing.T) {
+	want := BBox{-180, -MaxLatitude, 180, MaxLatitude}
+	if got := (Tile{}).Bounds(); !got.Equal(want, 1e-9) {
+		t.Errorf("0/0/0 bounds = %v, want %v", got, want)
+	}
+	if math.Abs(MaxLatitude-85.0511287798) > 1e-9 {
+		t.Errorf("MaxLatitude = %v, want 85.0511287798", MaxLatitude)
+	}
+}
+
+func TestTileForLonLat(t *testing.T) {
+	tests := []struct {
+		lon  float64
+		lat  float64
+		zoom int
+		want Tile
+	}{
+		{0, 0, 0, Tile{X: 0, Y: 0, Z: 0}},
+		// @mapbox/tilebelt's pointToTile example
+		{-77.03239381313323, 38.91326516559442, 10, Tile{X: 292, Y: 391, Z: 10}},
+		{0, 0, 1, Tile{X: 1, Y: 1, Z: 1}},
+		// the Web Mercator limit and beyond stay in the first and last row
+		{0, 85.0511, 8, Tile{X: 128, Y: 0, Z: 8}},
+		{0, -85.0511, 8, Tile{X: 128, Y: 255, Z: 8}},
+		{0, 90, 8, Tile{X: 128, Y: 0, Z: 8}},
+		{0, -90, 8, Tile{X: 128, Y: 255, Z: 8}},
+		// both sides of the antimeridian
+		{-180, 0, 8, Tile{X: 0, Y: 128, Z: 8}},
+		{180, 0, 8, Tile{X: 255, Y: 128, Z: 8}},
+		{179.9999, 0, 8, Tile{X: 255, Y: 128, Z: 8}},
+		{-179.9999, 0, 8, Tile{X: 0, Y: 128, Z: 8}},
+	}
+	for _, test := range tests {
+		if got := TileForLonLat(test.lon, test.lat, test.zoom); got != test.want {
+			t.Errorf("TileForLonLat(%v, %v, %d) = %s, want %s", test.lon, test.lat, test.zoom, got, test.want)
+		}
+	}
+}
+
+func TestAntimeridianTilesMeet(t *testing.T) {
+	for _, zoom := range []int{0, 1, 8, 20} {
+		last := 1<<zoom - 1
+		if west := (Tile{X: 0, Z: zoom}).Bounds().West(); west != -180 {
+			t.Errorf("zoom %d: first column starts at %v, want -180", zoom, west)
+		}
+		if east := (Tile{X: last, Z: zoom}).Bounds().East(); east != 180 {
+			t.Errorf("zoom %d: last column ends at %v, want 180", zoom, east)
+		}
+		if north := (Tile{Z: zoom}).Bounds().North(); math.Abs(north-MaxLatitude) > 1e-9 {
+			t.Errorf("zoom %d: first row starts at %v, want %v", zoom, north, MaxLatitude)
+		}
+		if south := (Tile{Y: last, Z: zoom}).Bounds().South(); math.Abs(south+MaxLatitude) > 1e-9 {
+			t.Errorf("zoom %d: last row ends at %v, want %v", zoom, south, -MaxLatitude)
+		}
+	}
+}
+
+func TestQuadkeyRoundTrip(t *testing.T) {
+	tests := []struct {
+		tile    Tile
+		quadkey string
+	}{
+		{Tile{}, ""},
+		{Tile{X: 1, Y: 0, Z: 1}, "1"},
+		{Tile{X: 3, Y: 5, Z: 3}, "213"},
+		{Tile{X: 292, Y: 391, Z: 10}, "0320100322"},
+	}
+	for _, test := range tests {
+		if got := test.tile.Quadkey(); got != test.quadkey {
+			t.Errorf("%s quadkey = %q, want %q", test.tile, got, test.quadkey)
+		}
+		tile, err := TileFromQuadkey(test.quadkey)
+		if err != nil || tile != test.tile {
+			t.Errorf("TileFromQuadkey(%q) = %s, %v, want %s", test.quadkey, tile, err, test.tile)
+		}
+	}
+	if _, err := TileFromQuadkey("0124"); err == nil {
+		t.Error("expected an error for quadkey digit 4")
+	}
+}
+
+func TestChildrenCoverParent(t *testing.T) {
+	parent := Tile{X: 292, Y: 391, Z: 10}
+	bounds := parent.Bounds()
+	union := BBox{180, 90, -180, -90}
+	for _, child := range parent.Children() {
+		if child.Parent() != parent {
+			t.Errorf("%s has parent %s, want %s", child, child.Parent(), parent)
+		}
+		b := child.Bounds()
+		union = BBox{math.Min(union[0], b[0]), math.Min(union[1], b[1]), math.Max(union[2], b[2]), math.Max(union[3], b[3])}
+	}
+	if !union.Equal(bounds, 1e-12) {
+		t.Errorf("children cover %v, want %v", union, bounds)
+	}
+}
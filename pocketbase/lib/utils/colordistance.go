@@ -0,0 +1,281 @@
+package utils
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sync/atomic"
+)
+
+// ColorDistance names a way of measuring how far apart two colors are.
+type ColorDistance string
+
+const (
+	// DistanceHSL is the original weighted HSL distance.
+	DistanceHSL ColorDistance = "hsl"
+	// DistanceRGB is the Euclidean distance in sRGB.
+	DistanceRGB ColorDistance = "rgb"
+	// DistanceCIELAB is the Euclidean distance in CIELAB (CIE76).
+	DistanceCIELAB ColorDistance = "cielab"
+	// DistanceCIEDE2000 is the CIEDE2000 color difference.
+	DistanceCIEDE2000 ColorDistance = "ciede2000"
+)
+
+// DefaultColorDistance is used by palettes that don't choose one. It stays the
+// HSL distance landcovers were always matched with; palettes opt into the
+// perceptual ones.
+const DefaultColorDistance = DistanceHSL
+
+// Func returns the function measuring the distance.
+func (d ColorDistance) Func() (func(c1, c2 color.NRGBA) float64, error) {
+	switch d {
+	case DistanceHSL, "":
+		return HslColorDistance, nil
+	case DistanceRGB:
+		return RgbColorDistance, nil
+	case DistanceCIELAB:
+		return func(c1, c2 color.NRGBA) float64 {
+			return CIE76(RgbToLab(c1), RgbToLab(c2))
+		}, nil
+	case DistanceCIEDE2000:
+		return func(c1, c2 color.NRGBA) float64 {
+			return CIEDE2000(RgbToLab(c1), RgbToLab(c2))
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown color distance %q", d)
+}
+
+func RgbColorDistance(c1, c2 color.NRGBA) float64 {
+	dr := float64(c1.R) - float64(c2.R)
+	dg := float64(c1.G) - float64(c2.G)
+	db := float64(c1.B) - float64(c2.B)
+	return math.Sqrt(dr*dr + dg*dg + db*db)
+}
+
+// Lab is a color in CIELAB with a D65 white point.
+type Lab struct {
+	L float64
+	A float64
+	B float64
+}
+
+// srgbToLinear maps 8-bit sRGB channel values to linear light.
+var srgbToLinear = func() (table [256]float64) {
+	for i := range table {
+		v := float64(i) / 255
+		if v <= 0.04045 {
+			table[i] = v / 12.92
+		} else {
+			table[i] = math.Pow((v+0.055)/1.055, 2.4)
+		}
+	}
+	return table
+}()
+
+// RgbToLab converts an sRGB color to CIELAB.
+func RgbToLab(c color.NRGBA) Lab {
+	r, g, b := srgbToLinear[c.R], srgbToLinear[c.G], srgbToLinear[c.B]
+
+	x := (0.4124564*r + 0.3575761*g + 0.1804375*b) / 0.95047
+	y := 0.2126729*r + 0.7151522*g + 0.0721750*b
+	z := (0.0193339*r + 0.1191920*g + 0.9503041*b) / 1.08883
+
+	f := func(t float64) float64 {
+		const delta = 6.0 / 29
+		if t > delta*delta*delta {
+			return math.Cbrt(t)
+		}
+		return t/(3*delta*delta) + 4.0/29
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+
+	return Lab{L: 116*fy - 16, A: 500 * (fx - fy), B: 200 * (fy - fz)}
+}
+
+func CIE76(lab1, lab2 Lab) float64 {
+	dl, da, db := lab1.L-lab2.L, lab1.A-lab2.A, lab1.B-lab2.B
+	return math.Sqrt(dl*dl + da*da + db*db)
+}
+
+// CIEDE2000 returns the CIEDE2000 color difference, following Sharma, Wu and
+// Dalal's formulation.
+func CIEDE2000(lab1, lab2 Lab) float64 {
+	const pow25To7 = 6103515625.0
+	pow7 := func(v float64) float64 {
+		v2 := v * v
+		return v2 * v2 * v2 * v
+	}
+	radians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+	hue := func(b, a float64) float64 {
+		if a == 0 && b == 0 {
+			return 0
+		}
+		h := math.Atan2(b, a) * 180 / math.Pi
+		if h < 0 {
+			h += 360
+		}
+		return h
+	}
+
+	cBar := (math.Hypot(lab1.A, lab1.B) + math.Hypot(lab2.A, lab2.B)) / 2
+	cBar7 := pow7(cBar)
+	g := 0.5 * (1 - math.Sqrt(cBar7/(cBar7+pow25To7)))
+
+	a1, a2 := (1+g)*lab1.A, (1+g)*lab2.A
+	c1, c2 := math.Hypot(a1, lab1.B), math.Hypot(a2, lab2.B)
+	h1, h2 := hue(lab1.B, a1), hue(lab2.B, a2)
+
+	dL := lab2.L - lab1.L
+	dC := c2 - c1
+	dh := 0.0
+	if c1*c2 != 0 {
+		dh = h2 - h1
+		if dh > 180 {
+			dh -= 360
+		} else if dh < -180 {
+			dh += 360
+		}
+	}
+	dH := 2 * math.Sqrt(c1*c2) * math.Sin(radians(dh/2))
+
+	lBar := (lab1.L + lab2.L) / 2
+	cBarPrime := (c1 + c2) / 2
+	hBar := h1 + h2
+	if c1*c2 != 0 {
+		switch {
+		case math.Abs(h1-h2) <= 180:
+			hBar = (h1 + h2) / 2
+		case h1+h2 < 360:
+			hBar = (h1 + h2 + 360) / 2
+		default:
+			hBar = (h1 + h2 - 360) / 2
+		}
+	}
+
+	t := 1 - 0.17*math.Cos(radians(hBar-30)) + 0.24*math.Cos(radians(2*hBar)) +
+		0.32*math.Cos(radians(3*hBar+6)) - 0.20*math.Cos(radians(4*hBar-63))
+	dTheta := 30 * math.Exp(-((hBar-275)/25)*((hBar-275)/25))
+	cBarPrime7 := pow7(cBarPrime)
+	rC := 2 * math.Sqrt(cBarPrime7/(cBarPrime7+pow25To7))
+	sL := 1 + 0.015*(lBar-50)*(lBar-50)/math.Sqrt(20+(lBar-50)*(lBar-50))
+	sC := 1 + 0.045*cBarPrime
+	sH := 1 + 0.015*cBarPrime*t
+	rT := -math.Sin(radians(2*dTheta)) * rC
+
+	return math.Sqrt((dL/sL)*(dL/sL) + (dC/sC)*(dC/sC) + (dH/sH)*(dH/sH) + rT*(dC/sC)*(dH/sH))
+}
+
+// lutBlockBits is how many high bits of each channel pick a block of the
+// lookup table. Blocks are filled the first time a color inside is matched,
+// so the table only costs time for the parts of the color cube images use.
+const lutBlockBits = 5
+
+// LandcoverPalette is a set of landcover classes matched with a color
+// distance. Matches are cached in a lookup table over the 24-bit color cube.
+type LandcoverPalette struct {
+	Classes  []Landcover
+	Distance ColorDistance
+
+	distance func(c1, c2 color.NRGBA) float64
+	// the classes in CIELAB, for the distances measured there
+	labs   []Lab
+	blocks [1 << (3 * lutBlockBits)]atomic.Pointer[[1 << (3 * (8 - lutBlockBits))]uint8]
+}
+
+func NewLandcoverPalette(classes []Landcover, distance ColorDistance) (*LandcoverPalette, error) {
+	if len(classes) > math.MaxUint8 {
+		return nil, fmt.Errorf("palettes hold at most %d classes, got %d", math.MaxUint8, len(classes))
+	}
+	if distance == "" {
+		distance = DefaultColorDistance
+	}
+	distanceFunc, err := distance.Func()
+	if err != nil {
+		return nil, err
+	}
+
+	palette := &LandcoverPalette{
+		Classes:  classes,
+		Distance: distance,
+		distance: distanceFunc,
+	}
+	if distance == DistanceCIELAB || distance == DistanceCIEDE2000 {
+		for _, class := range classes {
+			palette.labs = append(palette.labs, RgbToLab(class.Color))
+		}
+	}
+	return palette, nil
+}
+
+func mustLandcoverPalette(classes []Landcover, distance ColorDistance) *LandcoverPalette {
+	palette, err := NewLandcoverPalette(classes, distance)
+	if err != nil {
+		panic(err)
+	}
+	return palette
+}
+
+// nearest returns the index of the class closest to c without the table.
+func (p *LandcoverPalette) nearest(c color.NRGBA) int {
+	minDistance := math.MaxFloat64
+	index := 0
+	var lab Lab
+	if p.labs != nil {
+		lab = RgbToLab(c)
+	}
+	for i, class := range p.Classes {
+		var d float64
+		switch p.Distance {
+		case DistanceCIELAB:
+			d = CIE76(lab, p.labs[i])
+		case DistanceCIEDE2000:
+			d = CIEDE2000(lab, p.labs[i])
+		default:
+			d = p.distance(c, class.Color)
+		}
+		if d < minDistance {
+			minDistance = d
+			index = i
+		}
+	}
+	return index
+}
+
+// Index returns the index of the class closest to c, ignoring alpha, or -1
+// for an empty palette.
+func (p *LandcoverPalette) Index(c color.NRGBA) int {
+	if len(p.Classes) == 0 {
+		return -1
+	}
+
+	const shift = 8 - lutBlockBits
+	block := int(c.R>>shift)<<(2*lutBlockBits) | int(c.G>>shift)<<lutBlockBits | int(c.B>>shift)
+	const mask = 1<<shift - 1
+	offset := int(c.R&mask)<<(2*shift) | int(c.G&mask)<<shift | int(c.B&mask)
+
+	table := p.blocks[block].Load()
+	if table == nil {
+		// two goroutines may fill the same block, they come to the same result
+		table = new([1 << (3 * shift)]uint8)
+		for i := range table {
+			table[i] = uint8(p.nearest(color.NRGBA{
+				R: c.R&^mask | uint8(i>>(2*shift)),
+				G: c.G&^mask | uint8(i>>shift&mask),
+				B: c.B&^mask | uint8(i&mask),
+				A: 255,
+			}))
+		}
+		p.blocks[block].Store(table)
+	}
+
+	return int(table[offset])
+}
+
+// Closest returns the class closest to c.
+func (p *LandcoverPalette) Closest(c color.Color) Landcover {
+	index := p.Index(color.NRGBAModel.Convert(c).(color.NRGBA))
+	if index < 0 {
+		return Landcover{}
+	}
+	return p.Classes[index]
+}
@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// Reference pairs from Sharma, Wu and Dalal, "The CIEDE2000 Color-Difference
+// Formula: Implementation Notes, Supplementary Test Data, and Mathematical
+// Observations" (2005).
+func TestCIEDE2000(t *testing.T) {
+	tests := []struct {
+		lab1 Lab
+		lab2 Lab
+		want float64
+	}{
+		{Lab{50, 2.6772, -79.7751}, Lab{50, 0, -82.7485}, 2.0425},
+		{Lab{50, 0, 0}, Lab{50, -1, 2}, 2.3669},
+		{Lab{50, 2.5, 0}, Lab{61, -5, 29}, 22.8977},
+		{Lab{50, 2.5, 0}, Lab{56, -27, -3}, 31.9030},
+		{Lab{50, 2.5, 0}, Lab{58, 24, 15}, 19.4535},
+		{Lab{60.2574, -34.0099, 36.2677}, Lab{60.4626, -34.1751, 39.4387}, 1.2644},
+		{Lab{22.7233, 20.0904, -46.6940}, Lab{23.0331, 14.9730, -42.5619}, 2.0373},
+	}
+	for _, test := range tests {
+		for _, pair := range [][2]Lab{{test.lab1, test.lab2}, {test.lab2, test.lab1}} {
+			if got := CIEDE2000(pair[0], pair[1]); math.Abs(got-test.want) > 1e-4 {
+				t.Errorf("CIEDE2000(%v, %v) = %.4f, want %.4f", pair[0], pair[1], got, test.want)
+			}
+		}
+	}
+}
+
+func TestRgbToLab(t *testing.T) {
+	tests := []struct {
+		c    color.NRGBA
+		want Lab
+	}{
+		{color.NRGBA{A: 255}, Lab{0, 0, 0}},
+		{color.NRGBA{R: 255, G: 255, B: 255, A: 255}, Lab{100, 0, 0}},
+		{color.NRGBA{R: 255, A: 255}, Lab{53.2408, 80.0925, 67.2032}},
+		{color.NRGBA{G: 255, A: 255}, Lab{87.7347, -86.1827, 83.1793}},
+		{color.NRGBA{B: 255, A: 255}, Lab{32.2970, 79.1875, -107.8602}},
+	}
+	for _, test := range tests {
+		got := RgbToLab(test.c)
+		if math.Abs(got.L-test.want.L) > 1e-2 || math.Abs(got.A-test.want.A) > 1e-2 || math.Abs(got.B-test.want.B) > 1e-2 {
+			t.Errorf("RgbToLab(%v) = %v, want %v", test.c, got, test.want)
+		}
+	}
+}
+
+func TestHslDistanceWrapsAroundHue(t *testing.T) {
+	// hue 358° and 2°, both saturated reds
+	below := color.NRGBA{R: 255, G: 0, B: 9, A: 255}
+	above := color.NRGBA{R: 255, G: 9, B: 0, A: 255}
+	cyan := color.NRGBA{R: 0, G: 255, B: 255, A: 255}
+
+	across := HslColorDistance(below, above)
+	if across > 0.02 {
+		t.Errorf("reds on both sides of 0° are %v apart, want about 4/360", across)
+	}
+	if opposite := HslColorDistance(below, cyan); opposite < 0.49 {
+		t.Errorf("red and cyan are %v apart, want about 0.5", opposite)
+	}
+
+	palette, err := NewLandcoverPalette([]Landcover{
+		{Name: "red", Color: color.NRGBA{R: 255, A: 255}},
+		{Name: "magenta", Color: color.NRGBA{R: 255, B: 255, A: 255}},
+		{Name: "yellow", Color: color.NRGBA{R: 255, G: 255, A: 255}},
+	}, DistanceHSL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []color.NRGBA{below, above} {
+		if got := palette.Closest(c).Name; got != "red" {
+			t.Errorf("%v matched %s, want red", c, got)
+		}
+	}
+}
+
+func TestPaletteDefaultsToHsl(t *testing.T) {
+	palette, err := NewLandcoverPalette(Palette.Classes, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if palette.Distance != DistanceHSL {
+		t.Errorf("default distance %q, want %q", palette.Distance, DistanceHSL)
+	}
+	if Palette.Distance != DistanceHSL {
+		t.Errorf("built-in palette distance %q, want %q", Palette.Distance, DistanceHSL)
+	}
+}
+
+func TestPaletteMatchesClassColorsExactly(t *testing.T) {
+	for _, distance := range []ColorDistance{DistanceHSL, DistanceRGB, DistanceCIELAB, DistanceCIEDE2000} {
+		palette, err := NewLandcoverPalette(Palette.Classes, distance)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, class := range palette.Classes {
+			if got := palette.Index(class.Color); got != i {
+				t.Errorf("%s: %s matched class %d, want %d", distance, class.Name, got, i)
+			}
+		}
+	}
+}
+
+// Anti-aliased class edges blend the two colors. A pixel mostly of one class
+// must snap to that class, not to a third one that happens to lie between.
+func TestPaletteSnapsAntiAliasedEdges(t *testing.T) {
+	palette, err := NewLandcoverPalette(Palette.Classes, DistanceCIEDE2000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := map[string]color.NRGBA{}
+	for _, class := range palette.Classes {
+		byName[class.Name] = class.Color
+	}
+	blend := func(a color.NRGBA, b color.NRGBA, t float64) color.NRGBA {
+		mix := func(x uint8, y uint8) uint8 {
+			return uint8(math.Round(float64(x)*(1-t) + float64(y)*t))
+		}
+		return color.NRGBA{R: mix(a.R, b.R), G: mix(a.G, b.G), B: mix(a.B, b.B), A: 255}
+	}
+
+	edges := [][2]string{
+		{"water", "trees"},
+		{"water", "grass"},
+		{"trees", "grass"},
+		{"grass", "crops"},
+		{"crops", "built"},
+		{"trees", "crops"},
+		{"bare", "built"},
+	}
+	for _, edge := range edges {
+		a, b := byName[edge[0]], byName[edge[1]]
+		if got := palette.Closest(blend(a, b, 0.25)).Name; got != edge[0] {
+			t.Errorf("75%% %s, 25%% %s matched %s, want %s", edge[0], edge[1], got, edge[0])
+		}
+		if got := palette.Closest(blend(a, b, 0.75)).Name; got != edge[1] {
+			t.Errorf("25%% %s, 75%% %s matched %s, want %s", edge[0], edge[1], got, edge[1])
+		}
+	}
+}
+
+func TestPaletteTableMatchesBruteForce(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for _, distance := range []ColorDistance{DistanceHSL, DistanceRGB, DistanceCIELAB, DistanceCIEDE2000} {
+		palette, err := NewLandcoverPalette(Palette.Classes, distance)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2000; i++ {
+			c := color.NRGBA{R: uint8(random.Intn(256)), G: uint8(random.Intn(256)), B: uint8(random.Intn(256)), A: 255}
+			if got, want := palette.Index(c), palette.nearest(c); got != want {
+				t.Fatalf("%s: %v matched %d from the table, %d directly", distance, c, got, want)
+			}
+		}
+	}
+}
+
+func TestEmptyPalette(t *testing.T) {
+	palette, err := NewLandcoverPalette(nil, DistanceRGB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := palette.Index(color.NRGBA{R: 1, A: 255}); got != -1 {
+		t.Errorf("empty palette matched %d, want -1", got)
+	}
+	if _, err := NewLandcoverPalette(nil, "lch"); err == nil {
+		t.Error("expected an error for an unknown distance")
+	}
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// IntFromEnv returns the environment variable key as an int, or fallback if
+// it is unset or not a number.
+func IntFromEnv(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// FloatFromEnv returns the environment variable key as a float64, or
+// fallback if it is unset or not a number.
+func FloatFromEnv(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// DurationFromEnv returns the environment variable key parsed by
+// time.ParseDuration (e.g. "30s"), or fallback if it is unset or invalid.
+func DurationFromEnv(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
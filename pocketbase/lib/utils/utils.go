@@ -2,6 +2,7 @@ package utils
 
 import (
 	"encoding/json"
+	"fmt"
 	"image"
 	"image/color"
 	"math"
@@ -18,20 +19,27 @@ type Landcover struct {
 	Color   color.NRGBA
 	Texture color.NRGBA
 	Name    string
+	ClassId int
+	// Priority lets the class win a downsampled cell it covers at least
+	// MinCoverage of, even when another class is more common. 0 is off.
+	Priority    int
+	MinCoverage float64
 }
 
-var Palette = []Landcover{
-	{Color: color.NRGBA{R: 65, G: 155, B: 223, A: 255}, Name: "water", Texture: color.NRGBA{R: 255, G: 255, B: 0, A: 255}},
-	{Color: color.NRGBA{R: 57, G: 125, B: 73, A: 255}, Name: "trees", Texture: color.NRGBA{R: 0, G: 255, B: 255, A: 255}},
-	{Color: color.NRGBA{R: 136, G: 176, B: 83, A: 255}, Name: "grass", Texture: color.NRGBA{R: 0, G: 255, B: 0, A: 255}},
-	{Color: color.NRGBA{R: 122, G: 135, B: 198, A: 255}, Name: "flooded_vegetation", Texture: color.NRGBA{R: 255, G: 0, B: 0, A: 255}},
-	{Color: color.NRGBA{R: 228, G: 150, B: 53, A: 255}, Name: "crops", Texture: color.NRGBA{R: 0, G: 255, B: 255, A: 0}},
-	{Color: color.NRGBA{R: 223, G: 195, B: 90, A: 255}, Name: "shrub", Texture: color.NRGBA{R: 255, G: 0, B: 255, A: 255}},
-	{Color: color.NRGBA{R: 196, G: 40, B: 27, A: 255}, Name: "built", Texture: color.NRGBA{R: 255, G: 0, B: 0, A: 255}},
-	{Color: color.NRGBA{R: 165, G: 155, B: 143, A: 255}, Name: "bare", Texture: color.NRGBA{R: 0, G: 255, B: 0, A: 0}},
-	{Color: color.NRGBA{R: 179, G: 159, B: 225, A: 255}, Name: "snow", Texture: color.NRGBA{R: 255, G: 255, B: 255, A: 255}},
+// Palette is the built-in Dynamic World palette, used when the database has
+// no default palette.
+var Palette = mustLandcoverPalette([]Landcover{
+	{Color: color.NRGBA{R: 65, G: 155, B: 223, A: 255}, Name: "water", Texture: color.NRGBA{R: 255, G: 255, B: 0, A: 255}, ClassId: 0},
+	{Color: color.NRGBA{R: 57, G: 125, B: 73, A: 255}, Name: "trees", Texture: color.NRGBA{R: 0, G: 255, B: 255, A: 255}, ClassId: 1},
+	{Color: color.NRGBA{R: 136, G: 176, B: 83, A: 255}, Name: "grass", Texture: color.NRGBA{R: 0, G: 255, B: 0, A: 255}, ClassId: 2},
+	{Color: color.NRGBA{R: 122, G: 135, B: 198, A: 255}, Name: "flooded_vegetation", Texture: color.NRGBA{R: 255, G: 0, B: 0, A: 255}, ClassId: 3},
+	{Color: color.NRGBA{R: 228, G: 150, B: 53, A: 255}, Name: "crops", Texture: color.NRGBA{R: 0, G: 255, B: 255, A: 0}, ClassId: 4},
+	{Color: color.NRGBA{R: 223, G: 195, B: 90, A: 255}, Name: "shrub", Texture: color.NRGBA{R: 255, G: 0, B: 255, A: 255}, ClassId: 5},
+	{Color: color.NRGBA{R: 196, G: 40, B: 27, A: 255}, Name: "built", Texture: color.NRGBA{R: 255, G: 0, B: 0, A: 255}, ClassId: 6},
+	{Color: color.NRGBA{R: 165, G: 155, B: 143, A: 255}, Name: "bare", Texture: color.NRGBA{R: 0, G: 255, B: 0, A: 0}, ClassId: 7},
+	{Color: color.NRGBA{R: 179, G: 159, B: 225, A: 255}, Name: "snow", Texture: color.NRGBA{R: 255, G: 255, B: 255, A: 255}, ClassId: 8},
 	// Add more colors with names as needed
-}
+}, DefaultColorDistance)
 
 // RGB to HSL conversion
 func RgbToHSL(c color.NRGBA) (float64, float64, float64) {
@@ -71,7 +79,9 @@ func HslColorDistance(c1, c2 color.NRGBA) float64 {
 	h1, s1, l1 := RgbToHSL(c1)
 	h2, s2, l2 := RgbToHSL(c2)
 
-	hDiff := h1 - h2
+	// hue is circular, 0.99 and 0.01 are neighbors
+	hDiff := math.Abs(h1 - h2)
+	hDiff = math.Min(hDiff, 1-hDiff)
 	sDiff := s1 - s2
 	lDiff := l1 - l2
 
@@ -79,21 +89,25 @@ func HslColorDistance(c1, c2 color.NRGBA) float64 {
 }
 
 // Function to find the closest color from the palette
-func ClosestColor(c color.Color, palette []Landcover) color.NRGBA {
-	minDistance := math.MaxFloat64
-	var closest color.NRGBA
-	c1 := color.NRGBAModel.Convert(c).(color.NRGBA)
-	for _, pc := range palette {
-		d := HslColorDistance(c1, pc.Color)
-		if d < minDistance {
-			minDistance = d
-			closest = pc.Color
+func ClosestColor(c color.Color, palette *LandcoverPalette) color.NRGBA {
+	return palette.Closest(c).Color
+}
+
+// TextureImage paints every pixel of a landcover image with the texture
+// color of its closest class, giving the channel-encoded splat map terrain
+// shaders read.
+func TextureImage(img image.Image, palette *LandcoverPalette) *image.NRGBA {
+	bounds := img.Bounds()
+	texture := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			texture.SetNRGBA(x-bounds.Min.X, y-bounds.Min.Y, palette.Closest(img.At(x, y)).Texture)
 		}
 	}
-	return closest
+	return texture
 }
 
-func CalculateColorPercentages(img image.Image, palette []Landcover) (string, error) {
+func CalculateColorPercentages(img image.Image, palette *LandcoverPalette) (string, error) {
 	// Calculate color percentages and return a JSON map with color names
 	colorCounts := make(map[string]int)
 	totalPixels := 0
@@ -124,17 +138,8 @@ func CalculateColorPercentages(img image.Image, palette []Landcover) (string, er
 }
 
 // Helper function to find the closest color by name
-func GetClosestColorName(c color.NRGBA, palette []Landcover) string {
-	minDistance := math.MaxFloat64
-	closestName := ""
-	for _, cnPair := range palette {
-		d := HslColorDistance(c, cnPair.Color) // Assumes hslColorDistance is defined
-		if d < minDistance {
-			minDistance = d
-			closestName = cnPair.Name
-		}
-	}
-	return closestName
+func GetClosestColorName(c color.NRGBA, palette *LandcoverPalette) string {
+	return palette.Closest(c).Name
 }
 
 func GetImageForField(record *models.Record, collection *models.Collection, dir string, fieldName string, newPrefix string) (image.Image, string) {
@@ -160,6 +165,10 @@ func GetImageForField(record *models.Record, collection *models.Collection, dir
 }
 
 func GetFilePathForField(record *models.Record, collection *models.Collection, dir string, fieldName string) string {
+	return GetFilePathForFieldWithExtension(record, collection, dir, fieldName, ".png")
+}
+
+func GetFilePathForFieldWithExtension(record *models.Record, collection *models.Collection, dir string, fieldName string, extension string) string {
 	recordFolder := path.Join(dir, "storage", collection.GetId(), record.GetId())
 	if _, err := os.Stat(recordFolder); os.IsNotExist(err) {
 		os.MkdirAll(recordFolder, os.ModePerm)
@@ -167,16 +176,28 @@ func GetFilePathForField(record *models.Record, collection *models.Collection, d
 
 	filePath := path.Join(
 		recordFolder,
-		fieldName+".png",
+		fieldName+extension,
 	)
 	return filePath
 }
 
+// GetPathForFileField returns where the file stored in fieldName of the
+// record lives on disk.
+func GetPathForFileField(record *models.Record, collection *models.Collection, dir string, fieldName string) string {
+	return path.Join(
+		dir,
+		"storage",
+		collection.GetId(),
+		record.GetId(),
+		record.GetString(fieldName),
+	)
+}
+
 func GetFileNameForPath(filePath string) string {
 	return strings.Split(filePath, "/")[len(strings.Split(filePath, "/"))-1]
 }
 
-func CoordsFromBboxString(bboxString string) []float64 {
+func CoordsFromBboxString(bboxString string) ([]float64, error) {
 	bboxString = strings.Replace(bboxString, "[", "", -1)
 	bboxString = strings.Replace(bboxString, "]", "", -1)
 
@@ -184,12 +205,21 @@ func CoordsFromBboxString(bboxString string) []float64 {
 
 	coords := make([]float64, 0)
 	for _, value := range values {
-		coord, _ := strconv.ParseFloat(value, 64)
+		coord, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bbox %q: %w", bboxString, err)
+		}
 		coords = append(coords, coord)
 	}
-	return coords
+	if len(coords) != 4 {
+		return nil, fmt.Errorf("invalid bbox %q: expected 4 values, got %d", bboxString, len(coords))
+	}
+	return coords, nil
 }
 
-func GetCenterOfBbox(bbox []float64) (float64, float64) {
-	return (bbox[0] + bbox[2]) / 2, (bbox[1] + bbox[3]) / 2
+func GetCenterOfBbox(bbox []float64) (float64, float64, error) {
+	if len(bbox) < 4 {
+		return 0, 0, fmt.Errorf("invalid bbox: expected 4 values, got %d", len(bbox))
+	}
+	return (bbox[0] + bbox[2]) / 2, (bbox[1] + bbox[3]) / 2, nil
 }
@@ -1,21 +1,32 @@
 package main
 
 import (
+	"app/lib/classify"
+	"app/lib/elevation"
+	"app/lib/jobs"
 	"app/lib/mapbox"
+	"app/lib/mosaic"
+	"app/lib/palettes"
+	"app/lib/tilecache"
+	"app/lib/tilemath"
 	utils "app/lib/utils"
+	"fmt"
+	"image"
 	"image/color"
 	"log"
-	"math"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"os"
+	"path"
 	"strings"
 
 	_ "app/migrations"
 
 	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
 
 	"github.com/disintegration/imaging"
 	"github.com/pocketbase/pocketbase/core"
@@ -23,96 +34,101 @@ import (
 	"github.com/pocketbase/pocketbase/plugins/migratecmd"
 )
 
-func onLandcoverUpdate(record *models.Record, collection *models.Collection, app *pocketbase.PocketBase) error {
+func onLandcoverUpdate(record *models.Record, collection *models.Collection, app *pocketbase.PocketBase, palette *utils.LandcoverPalette) error {
 	if record.GetString("color") == "" {
-		onLandcoverCreate(record, collection, app)
-		return nil
+		return onLandcoverCreate(record, collection, app, palette)
 	}
 
 	src, newFilePath := utils.GetImageForField(record, collection, app.DataDir(), "color", "color_100")
+	if src == nil {
+		return fmt.Errorf("landcover %s: can't open the color image", record.Id)
+	}
 
-	resized := imaging.Resize(src, 100, 100, imaging.NearestNeighbor)
-	newImage := imaging.New(resized.Bounds().Dx(), resized.Bounds().Dy(), color.NRGBA{})
-
-	// Loop over each pixel of the image.
-	bounds := newImage.Bounds()
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			originalColor := resized.At(x, y)
-			closest := utils.ClosestColor(originalColor, utils.Palette)
-
-			newImage.Set(x, y, closest)
-		}
+	// the landcover keeps a default grid for previews, tiles and simulations
+	// build their own
+	newImage, err := saveGridRasters(record, app, src, palette, defaultGridSize, defaultGridSize, newFilePath, gridFields{Color: "color_100", Texture: "texture_100", Coverage: "coverage_100"})
+	if err != nil {
+		return err
 	}
-	// set custom encodeOptions
-	// encodeOpts := {
-	// 	JPEGQuality: 100,
-	// }
-	imaging.Save(newImage, newFilePath, imaging.PNGCompressionLevel(-1))
 
-	record.Set("color_100", utils.GetFileNameForPath(newFilePath))
+	// texture splat maps, each class painted with its palette texture color
+	texturePath := utils.GetFilePathForField(record, collection, app.DataDir(), "texture")
+	if err := imaging.Save(utils.TextureImage(src, palette), texturePath); err != nil {
+		return err
+	}
+	record.Set("texture", utils.GetFileNameForPath(texturePath))
 
 	// Calculate color percentages
-	jsonMap, _ := utils.CalculateColorPercentages(newImage, utils.Palette)
+	jsonMap, err := utils.CalculateColorPercentages(newImage, palette)
+	if err != nil {
+		return err
+	}
 	record.Set("coverage", jsonMap)
 
+	metrics, err := landcoverMetrics(record, app, src, palette)
+	if err != nil {
+		return err
+	}
+	record.Set("metrics", metrics)
+
 	return nil
 }
 
-func onLandcoverCreate(record *models.Record, collection *models.Collection, app *pocketbase.PocketBase) error {
+func onLandcoverCreate(record *models.Record, collection *models.Collection, app *pocketbase.PocketBase, palette *utils.LandcoverPalette) error {
 	src, newFilePath := utils.GetImageForField(record, collection, app.DataDir(), "original", "color")
+	if src == nil {
+		return fmt.Errorf("landcover %s: can't open the original image", record.Id)
+	}
 
-	newImage := imaging.New(src.Bounds().Dx(), src.Bounds().Dy(), color.NRGBA{})
-	bounds := newImage.Bounds()
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			originalColor := src.At(x, y)
-			closest := utils.ClosestColor(originalColor, utils.Palette)
+	// snap every pixel to its closest class, then clean up the speckle
+	classMap := classMapForImage(src, palette)
+	landcoverCleanup(record, app, classMap.Width).Apply(classMap)
 
-			newImage.Set(x, y, closest)
-		}
+	newImage := imageForClassMap(classMap, palette)
+	if err := imaging.Save(newImage, newFilePath); err != nil {
+		return err
 	}
-	imaging.Save(newImage, newFilePath)
 	record.Set("color", strings.Split(newFilePath, "/")[len(strings.Split(newFilePath, "/"))-1])
 
-	onLandcoverUpdate(record, collection, app)
-
-	return nil
+	return onLandcoverUpdate(record, collection, app, palette)
 }
 
 func onHeightmapCreate(record *models.Record, collection *models.Collection, app *pocketbase.PocketBase) error {
 	src, newFilePath := utils.GetImageForField(record, collection, app.DataDir(), "original", "heightmap")
 
-	// init minheight and maxheight as infinity and -infinity
-	minHeight := math.Inf(1)
-	maxHeight := math.Inf(-1)
-
-	newImage := imaging.New(src.Bounds().Dx(), src.Bounds().Dy(), color.NRGBA{})
-	bounds := newImage.Bounds()
-	// array of all heights
-	heights := make([]float64, 0)
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			r, g, b, _ := src.At(x, y).RGBA()
-			height := -10000 + float64(r*256*256+g*256+b)*0.1
-			heights = append(heights, height)
-			if height < minHeight {
-				minHeight = height
-			}
-			if height > maxHeight {
-				maxHeight = height
-			}
-		}
+	encoding := elevation.Encoding(record.GetString("encoding"))
+	if encoding == "" {
+		encoding = elevation.EncodingTerrainRGB
+		record.Set("encoding", string(encoding))
+	}
+	grid, err := elevation.Decode(src, encoding)
+	if err != nil {
+		return err
+	}
+	minHeight, maxHeight := grid.MinMax()
+
+	// keep the elevation in meters next to the normalized preview
+	elevationPath := utils.GetFilePathForFieldWithExtension(record, collection, app.DataDir(), "elevation", ".f32")
+	if err := grid.WriteFile(elevationPath); err != nil {
+		return err
 	}
-	// normalize all heights
-	for i, height := range heights {
-		heights[i] = (height - minHeight) / (maxHeight - minHeight)
+	record.Set("elevation", utils.GetFileNameForPath(elevationPath))
+	record.Set("elevationInfo", grid.Info())
+
+	if metersPerPixel := metersPerPixelForHeightmap(record, app); metersPerPixel > 0 {
+		if err := createTerrainDerivatives(record, collection, app, grid, metersPerPixel); err != nil {
+			return err
+		}
 	}
 
 	// set newImage to grayscale
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			height := heights[y*src.Bounds().Dx()+x]
+	newImage := imaging.New(grid.Width, grid.Height, color.NRGBA{})
+	for y := 0; y < grid.Height; y++ {
+		for x := 0; x < grid.Width; x++ {
+			height := 0.0
+			if maxHeight > minHeight {
+				height = (grid.At(x, y) - minHeight) / (maxHeight - minHeight)
+			}
 			newImage.Set(x, y, color.NRGBA{
 				R: uint8(height * 255),
 				G: uint8(height * 255),
@@ -130,6 +146,250 @@ func onHeightmapCreate(record *models.Record, collection *models.Collection, app
 	return nil
 }
 
+// metersPerPixelForHeightmap returns the ground resolution of the tile the
+// heightmap belongs to, or 0 if it isn't attached to a tile.
+func metersPerPixelForHeightmap(record *models.Record, app *pocketbase.PocketBase) float64 {
+	tile, err := app.Dao().FindFirstRecordByFilter("tiles", "heightmap = {:id}", dbx.Params{"id": record.Id})
+	if err != nil {
+		return 0
+	}
+	return tile.GetFloat("metersPerPixel")
+}
+
+// createTerrainDerivatives renders hillshade, slope, aspect and normal map
+// images for a heightmap and stores the mean and max slope in degrees.
+func createTerrainDerivatives(record *models.Record, collection *models.Collection, app *pocketbase.PocketBase, grid *elevation.Grid, metersPerPixel float64) error {
+	// 0 means unset for each, light from the north is an azimuth of 360
+	azimuth := record.GetFloat("sunAzimuth")
+	if azimuth == 0 {
+		azimuth = 315
+		record.Set("sunAzimuth", azimuth)
+	}
+	altitude := record.GetFloat("sunAltitude")
+	if altitude == 0 {
+		altitude = 45
+		record.Set("sunAltitude", altitude)
+	}
+
+	hillshadePath := utils.GetFilePathForField(record, collection, app.DataDir(), "hillshade")
+	if err := imaging.Save(grid.Hillshade(metersPerPixel, azimuth, altitude), hillshadePath); err != nil {
+		return err
+	}
+	record.Set("hillshade", utils.GetFileNameForPath(hillshadePath))
+
+	slope := grid.Slope(metersPerPixel)
+	slopePath := utils.GetFilePathForField(record, collection, app.DataDir(), "slope")
+	if err := imaging.Save(slope.Gray(0, 90), slopePath); err != nil {
+		return err
+	}
+	record.Set("slope", utils.GetFileNameForPath(slopePath))
+	_, maxSlope := slope.MinMax()
+	record.Set("meanSlope", slope.Mean())
+	record.Set("maxSlope", maxSlope)
+
+	// flat pixels (-1) end up black, north facing pixels nearly black
+	aspectPath := utils.GetFilePathForField(record, collection, app.DataDir(), "aspect")
+	if err := imaging.Save(grid.Aspect(metersPerPixel).Gray(-1, 360), aspectPath); err != nil {
+		return err
+	}
+	record.Set("aspect", utils.GetFileNameForPath(aspectPath))
+
+	strength := record.GetFloat("normalStrength")
+	if strength == 0 {
+		strength = 1
+		record.Set("normalStrength", strength)
+	}
+	normalMapPath := utils.GetFilePathForField(record, collection, app.DataDir(), "normalMap")
+	if err := imaging.Save(grid.NormalMap(metersPerPixel, strength), normalMapPath); err != nil {
+		return err
+	}
+	record.Set("normalMap", utils.GetFileNameForPath(normalMapPath))
+
+	return nil
+}
+
+// onTileCreate downloads the satellite image and heightmap for a tile and
+// processes the heightmap. Steps that already completed on a previous attempt
+// are skipped.
+func onTileCreate(record *models.Record, app *pocketbase.PocketBase, progress func(percent int)) error {
+	x := record.GetInt("x")
+	y := record.GetInt("y")
+	zoom := record.GetInt("zoom")
+	record.Set("status", "processing")
+	progress(0)
+
+	provider, err := mapbox.ProviderForName(record.GetString("provider"))
+	if err != nil {
+		return err
+	}
+	record.Set("provider", provider.Name())
+
+	fetchImagery := provider.FetchImagery
+	fetchElevation := provider.FetchElevation
+	_, lat := tilemath.Tile{X: x, Y: y, Z: zoom}.Center()
+
+	// regions are stitched from all tiles covering their geometry
+	geometry := record.GetString("geometry")
+	isRegion := geometry != "" && geometry != "null"
+	if isRegion {
+		polygon, err := mosaic.ParsePolygon([]byte(geometry))
+		if err != nil {
+			return err
+		}
+		region := mosaic.Region{Polygon: polygon, Zoom: zoom, TileSize: provider.TileSize()}
+		_, lat = region.BBox().Center()
+
+		fetchImagery = func(x int, y int, zoom int) (image.Image, error) {
+			img, err := region.Stitch(provider.FetchImagery)
+			if err != nil {
+				return nil, err
+			}
+			region.Mask(img)
+			return img, nil
+		}
+		fetchElevation = func(x int, y int, zoom int) (image.Image, error) {
+			img, err := region.Stitch(provider.FetchElevation)
+			if err != nil {
+				return nil, err
+			}
+			return img, nil
+		}
+	}
+
+	if record.GetString("satellite") == "" {
+		image, err := fetchImagery(x, y, zoom)
+		if err != nil {
+			return fmt.Errorf("satellite: %w", err)
+		}
+
+		filePath := utils.GetFilePathForField(record, record.Collection(), app.DataDir(), "satellite")
+		if err := imaging.Save(image, filePath); err != nil {
+			return fmt.Errorf("satellite: %w", err)
+		}
+		record.Set("satellite", utils.GetFileNameForPath(filePath))
+	}
+	progress(40)
+
+	collection, err := app.Dao().FindCollectionByNameOrId("heightmaps")
+	if err != nil {
+		return err
+	}
+
+	var heightmap *models.Record
+	if heightmapId := record.GetString("heightmap"); heightmapId != "" {
+		heightmap, err = app.Dao().FindRecordById(collection.Id, heightmapId)
+		if err != nil {
+			return err
+		}
+	} else {
+		image, err := fetchElevation(x, y, zoom)
+		if err != nil {
+			return fmt.Errorf("heightmap: %w", err)
+		}
+
+		heightmap = models.NewRecord(collection)
+		heightmap.Set("encoding", string(provider.Encoding()))
+		if err := app.Dao().SaveRecord(heightmap); err != nil {
+			return err
+		}
+
+		filePath := utils.GetFilePathForField(heightmap, collection, app.DataDir(), "original")
+		if err := imaging.Save(image, filePath); err != nil {
+			return fmt.Errorf("heightmap: %w", err)
+		}
+		heightmap.Set("original", utils.GetFileNameForPath(filePath))
+		if err := app.Dao().SaveRecord(heightmap); err != nil {
+			return err
+		}
+
+		record.Set("heightmap", heightmap.Id)
+	}
+
+	// the resolution is that of the heightmap's pixels: regions are stitched
+	// at the provider's tile size, single tiles come at their own size
+	tileSize := provider.TileSize()
+	if !isRegion {
+		tileSize, err = imageWidth(utils.GetPathForFileField(heightmap, collection, app.DataDir(), "original"))
+		if err != nil {
+			return fmt.Errorf("heightmap: %w", err)
+		}
+	}
+	record.Set("metersPerPixel", mapbox.GroundResolution(zoom, lat, tileSize))
+	progress(70)
+
+	if heightmap.GetString("heightmap") == "" {
+		if err := onHeightmapCreate(heightmap, collection, app); err != nil {
+			return fmt.Errorf("heightmap: %w", err)
+		}
+		if err := app.Dao().SaveRecord(heightmap); err != nil {
+			return err
+		}
+	}
+
+	record.Set("status", "done")
+	record.Set("error", "")
+	progress(100)
+
+	return nil
+}
+
+// onTileBeforeCreate derives the bbox, center and corners of a tile from its
+// x/y/zoom, rejecting tiles whose supplied bbox doesn't match.
+func onTileBeforeCreate(record *models.Record) error {
+	tile := tilemath.Tile{
+		X: record.GetInt("x"),
+		Y: record.GetInt("y"),
+		Z: record.GetInt("zoom"),
+	}
+	if !tile.Valid() {
+		return apis.NewBadRequestError(fmt.Sprintf("Invalid tile %s.", tile), nil)
+	}
+
+	bbox := tile.Bounds()
+	if bboxString := record.GetString("bbox"); bboxString != "" && bboxString != "null" {
+		coords, err := utils.CoordsFromBboxString(bboxString)
+		if err != nil {
+			return apis.NewBadRequestError("Invalid bbox.", err)
+		}
+		if !bbox.Equal(tilemath.BBox(coords), 1e-6) {
+			return apis.NewBadRequestError(fmt.Sprintf("The bbox doesn't match tile %s, expected %v.", tile, bbox), nil)
+		}
+	}
+
+	lon, lat := tile.Center()
+	record.Set("bbox", bbox)
+	record.Set("center", []float64{lon, lat})
+	record.Set("corners", tile.Corners())
+
+	return nil
+}
+
+// imageWidth reads the width of an image file from its header.
+func imageWidth(filePath string) (int, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return 0, err
+	}
+	return config.Width, nil
+}
+
+// failTile marks a tile as failed so the error is visible on the record
+// instead of leaving it half-populated.
+func failTile(record *models.Record, app *pocketbase.PocketBase, err error) error {
+	log.Printf("Failed to process tile %s: %v", record.Id, err)
+
+	record.Set("status", "failed")
+	record.Set("error", err.Error())
+
+	return app.Dao().SaveRecord(record)
+}
+
 func main() {
 	app := pocketbase.New()
 
@@ -139,71 +399,197 @@ func main() {
 		Automigrate: isGoRun,
 	})
 
-	app.OnRecordAfterCreateRequest("tiles").Add(func(e *core.RecordCreateEvent) error {
-		x := e.Record.GetInt("x")
-		y := e.Record.GetInt("y")
-		zoom := e.Record.GetInt("zoom")
-		image := mapbox.DownloadSatelliteTile(x, y, zoom)
+	mapbox.TileCache = tilecache.NewFromEnv(path.Join(app.DataDir(), "tile_cache"))
+	app.RootCmd.AddCommand(tilecache.NewCommand(mapbox.TileCache))
 
-		filePath := utils.GetFilePathForField(e.Record, e.Collection, app.DataDir(), "satellite")
-		imaging.Save(image, filePath)
-		e.Record.Set("satellite", utils.GetFileNameForPath(filePath))
+	landcoverPalettes := palettes.New(app)
+	landcoverPalettes.Watch()
 
-		bboxString := e.Record.GetString("bbox")
-		metersPerPixel := mapbox.MeterPerPixelFromBboxAndZoom(zoom, bboxString)
-		e.Record.Set("metersPerPixel", metersPerPixel)
+	classify.RegisterClassifier("knn", &classify.KNN{
+		K:          utils.IntFromEnv("KNN_NEIGHBORS", 5),
+		MaxSamples: utils.IntFromEnv("KNN_MAX_SAMPLES", 10000),
+		Examples: func(palette *utils.LandcoverPalette) ([]classify.Example, error) {
+			return labeledExamples(app, landcoverPalettes, palette)
+		},
+	})
 
-		app.Dao().SaveRecord(e.Record)
+	queue := jobs.New(app, utils.IntFromEnv("JOB_WORKERS", 2))
 
-		return nil
+	queue.Handle("tile.create", func(job *jobs.Job) error {
+		record, err := app.Dao().FindRecordById("tiles", job.RecordId())
+		if err != nil {
+			return err
+		}
+
+		err = onTileCreate(record, app, func(percent int) {
+			record.Set("progress", percent)
+			app.Dao().SaveRecord(record)
+			job.Progress(percent)
+		})
+		if err != nil {
+			if job.LastAttempt() {
+				failTile(record, app, err)
+			} else {
+				record.Set("status", "pending")
+				record.Set("error", err.Error())
+				app.Dao().SaveRecord(record)
+			}
+		}
+		return err
 	})
 
-	app.OnRecordAfterCreateRequest("tiles").Add(func(e *core.RecordCreateEvent) error {
-		x := e.Record.GetInt("x")
-		y := e.Record.GetInt("y")
-		zoom := e.Record.GetInt("zoom")
-		image := mapbox.DownloadHeightmapTile(x, y, zoom)
+	queue.Handle("landcover.create", func(job *jobs.Job) error {
+		record, err := app.Dao().FindRecordById("landcovers", job.RecordId())
+		if err != nil {
+			return err
+		}
+
+		if err := onLandcoverCreate(record, record.Collection(), app, landcoverPalettes.ForRecord(record)); err != nil {
+			return err
+		}
 
-		collection, _ := app.Dao().FindCollectionByNameOrId("heightmaps")
-		heightmap := models.NewRecord(collection)
-		models.NewRecord(collection)
+		if err := app.Dao().SaveRecord(record); err != nil {
+			return err
+		}
+		return enqueueTileGrids(app, queue, record.Id)
+	})
 
-		app.Dao().SaveRecord(heightmap)
+	queue.Handle("landcover.update", func(job *jobs.Job) error {
+		record, err := app.Dao().FindRecordById("landcovers", job.RecordId())
+		if err != nil {
+			return err
+		}
 
-		filePath := utils.GetFilePathForField(heightmap, collection, app.DataDir(), "original")
-		imaging.Save(image, filePath)
-		heightmap.Set("original", utils.GetFileNameForPath(filePath))
-		app.Dao().SaveRecord(heightmap)
+		if err := onLandcoverUpdate(record, record.Collection(), app, landcoverPalettes.ForRecord(record)); err != nil {
+			return err
+		}
 
-		e.Record.Set("heightmap", heightmap.Id)
-		app.Dao().SaveRecord(e.Record)
+		if err := app.Dao().SaveRecord(record); err != nil {
+			return err
+		}
+		return enqueueTileGrids(app, queue, record.Id)
+	})
 
-		onHeightmapCreate(heightmap, collection, app)
+	queue.Handle("landcover.classify", func(job *jobs.Job) error {
+		record, err := app.Dao().FindRecordById("landcovers", job.RecordId())
+		if err != nil {
+			return err
+		}
 
-		app.Dao().SaveRecord(heightmap)
+		tile, err := onLandcoverClassify(record, app, landcoverPalettes.ForRecord(record))
+		if err != nil {
+			return err
+		}
 
-		return nil
+		if err := app.Dao().SaveRecord(record); err != nil {
+			return err
+		}
+		tile.Set("landcover", record.Id)
+		if err := app.Dao().SaveRecord(tile); err != nil {
+			return err
+		}
+		return enqueueTileGrids(app, queue, record.Id)
 	})
 
-	app.OnRecordAfterCreateRequest("landcovers").Add(func(e *core.RecordCreateEvent) error {
-		onLandcoverCreate(e.Record, e.Collection, app)
+	queue.Handle("tile.grid", func(job *jobs.Job) error {
+		record, err := app.Dao().FindRecordById("tiles", job.RecordId())
+		if err != nil {
+			return err
+		}
 
-		app.Dao().SaveRecord(e.Record)
+		if err := onTileGrid(record, app, landcoverPalettes); err != nil {
+			return err
+		}
 
-		return nil
+		return app.Dao().SaveRecord(record)
 	})
 
-	app.OnRecordAfterUpdateRequest("landcovers").Add(func(e *core.RecordUpdateEvent) error {
-		onLandcoverUpdate(e.Record, e.Collection, app)
+	queue.Handle("simulation.grid", func(job *jobs.Job) error {
+		record, err := app.Dao().FindRecordById("simulations", job.RecordId())
+		if err != nil {
+			return err
+		}
 
-		app.Dao().SaveRecord(e.Record)
-		return nil
+		if err := onSimulationGrid(record, app, landcoverPalettes); err != nil {
+			return err
+		}
+
+		return app.Dao().SaveRecord(record)
+	})
+
+	queue.Handle("heightmap.create", func(job *jobs.Job) error {
+		record, err := app.Dao().FindRecordById("heightmaps", job.RecordId())
+		if err != nil {
+			return err
+		}
+
+		if err := onHeightmapCreate(record, record.Collection(), app); err != nil {
+			return err
+		}
+
+		return app.Dao().SaveRecord(record)
+	})
+
+	app.OnRecordBeforeCreateRequest("tiles").Add(func(e *core.RecordCreateEvent) error {
+		return onTileBeforeCreate(e.Record)
+	})
+
+	app.OnRecordAfterCreateRequest("tiles").Add(func(e *core.RecordCreateEvent) error {
+		e.Record.Set("status", "pending")
+		e.Record.Set("progress", 0)
+		if err := app.Dao().SaveRecord(e.Record); err != nil {
+			return err
+		}
+
+		_, err := queue.Enqueue("tile.create", e.Record.Id)
+		return err
+	})
+
+	app.OnRecordAfterUpdateRequest("tiles").Add(func(e *core.RecordUpdateEvent) error {
+		if !gridChanged(e.Record, "landcover", "simulations") {
+			return nil
+		}
+		_, err := queue.Enqueue("tile.grid", e.Record.Id)
+		return err
+	})
+
+	app.OnRecordAfterCreateRequest("simulations").Add(func(e *core.RecordCreateEvent) error {
+		_, err := queue.Enqueue("simulation.grid", e.Record.Id)
+		return err
+	})
+
+	app.OnRecordAfterUpdateRequest("simulations").Add(func(e *core.RecordUpdateEvent) error {
+		if !gridChanged(e.Record) {
+			return nil
+		}
+		_, err := queue.Enqueue("simulation.grid", e.Record.Id)
+		return err
+	})
+
+	app.OnRecordAfterCreateRequest("landcovers").Add(func(e *core.RecordCreateEvent) error {
+		_, err := queue.Enqueue("landcover.create", e.Record.Id)
+		return err
+	})
+
+	app.OnRecordAfterUpdateRequest("landcovers").Add(func(e *core.RecordUpdateEvent) error {
+		_, err := queue.Enqueue("landcover.update", e.Record.Id)
+		return err
 	})
 
 	app.OnRecordAfterCreateRequest("heightmaps").Add(func(e *core.RecordCreateEvent) error {
-		onHeightmapCreate(e.Record, e.Collection, app)
+		_, err := queue.Enqueue("heightmap.create", e.Record.Id)
+		return err
+	})
+
+	app.OnBeforeServe().Add(func(e *core.ServeEvent) error {
+		if err := landcoverPalettes.Load(); err != nil {
+			return err
+		}
+		return queue.Start()
+	})
 
-		app.Dao().SaveRecord(e.Record)
+	app.OnTerminate().Add(func(e *core.TerminateEvent) error {
+		queue.Stop()
 		return nil
 	})
 
@@ -232,6 +618,38 @@ func main() {
 			}
 		})
 
+		e.Router.POST("/api/terrain/regions", func(c echo.Context) error {
+			return onRegionCreate(c, app, queue)
+		})
+
+		e.Router.GET("/api/terrain/elevation", func(c echo.Context) error {
+			return onElevationRequest(c, app)
+		})
+
+		e.Router.GET("/api/terrain/heightmaps/:id/export", func(c echo.Context) error {
+			return onHeightmapExport(c, app)
+		})
+
+		e.Router.GET("/api/terrain/tiles/:id/mesh", func(c echo.Context) error {
+			return onTileMesh(c, app)
+		})
+
+		e.Router.POST("/api/terrain/tiles/:id/classify", func(c echo.Context) error {
+			return onTileClassify(c, app, queue)
+		})
+
+		e.Router.GET("/api/terrain/landcovers/:id/splatmaps", func(c echo.Context) error {
+			return onSplatmapExport(c, app, landcoverPalettes)
+		})
+
+		e.Router.GET("/api/terrain/tiles/:id/cesium/layer.json", func(c echo.Context) error {
+			return onCesiumLayer(c, app)
+		})
+
+		e.Router.GET("/api/terrain/tiles/:id/cesium/:z/:x/:y", func(c echo.Context) error {
+			return onCesiumTile(c, app, landcoverPalettes)
+		})
+
 		e.Router.POST("/simulate/upload", func(c echo.Context) error {
 			log.Println("POST /simulate/upload")
 
@@ -0,0 +1,78 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("ewi0x38j6dujau8")
+		if err != nil {
+			return err
+		}
+
+		// add
+		new_status := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "z8r2mwhe",
+			"name": "status",
+			"type": "select",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"maxSelect": 1,
+				"values": [
+					"pending",
+					"processing",
+					"done",
+					"failed"
+				]
+			}
+		}`), new_status)
+		collection.Schema.AddField(new_status)
+
+		// add
+		new_error := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "bq0xv7tn",
+			"name": "error",
+			"type": "text",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"min": null,
+				"max": null,
+				"pattern": ""
+			}
+		}`), new_error)
+		collection.Schema.AddField(new_error)
+
+		return dao.SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("ewi0x38j6dujau8")
+		if err != nil {
+			return err
+		}
+
+		// remove
+		collection.Schema.RemoveField("z8r2mwhe")
+
+		// remove
+		collection.Schema.RemoveField("bq0xv7tn")
+
+		return dao.SaveCollection(collection)
+	})
+}
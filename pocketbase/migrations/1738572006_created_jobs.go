@@ -0,0 +1,165 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		jsonData := `{
+			"id": "b2ojx7w5kzq1m9d",
+			"created": "2025-02-03 08:40:06.118Z",
+			"updated": "2025-02-03 08:40:06.118Z",
+			"name": "jobs",
+			"type": "base",
+			"system": false,
+			"schema": [
+				{
+					"system": false,
+					"id": "ud7yfxrs",
+					"name": "type",
+					"type": "text",
+					"required": true,
+					"presentable": true,
+					"unique": false,
+					"options": {
+						"min": null,
+						"max": null,
+						"pattern": ""
+					}
+				},
+				{
+					"system": false,
+					"id": "v1cxk3ra",
+					"name": "record",
+					"type": "text",
+					"required": true,
+					"presentable": false,
+					"unique": false,
+					"options": {
+						"min": null,
+						"max": null,
+						"pattern": ""
+					}
+				},
+				{
+					"system": false,
+					"id": "mg46pldn",
+					"name": "status",
+					"type": "select",
+					"required": true,
+					"presentable": false,
+					"unique": false,
+					"options": {
+						"maxSelect": 1,
+						"values": [
+							"pending",
+							"running",
+							"failed",
+							"done"
+						]
+					}
+				},
+				{
+					"system": false,
+					"id": "h5tw8ojq",
+					"name": "attempts",
+					"type": "number",
+					"required": false,
+					"presentable": false,
+					"unique": false,
+					"options": {
+						"min": null,
+						"max": null,
+						"noDecimal": true
+					}
+				},
+				{
+					"system": false,
+					"id": "e0zq3jbn",
+					"name": "maxAttempts",
+					"type": "number",
+					"required": false,
+					"presentable": false,
+					"unique": false,
+					"options": {
+						"min": null,
+						"max": null,
+						"noDecimal": true
+					}
+				},
+				{
+					"system": false,
+					"id": "r7nfa2ck",
+					"name": "progress",
+					"type": "number",
+					"required": false,
+					"presentable": false,
+					"unique": false,
+					"options": {
+						"min": 0,
+						"max": 100,
+						"noDecimal": false
+					}
+				},
+				{
+					"system": false,
+					"id": "k9wsp4ge",
+					"name": "error",
+					"type": "text",
+					"required": false,
+					"presentable": false,
+					"unique": false,
+					"options": {
+						"min": null,
+						"max": null,
+						"pattern": ""
+					}
+				},
+				{
+					"system": false,
+					"id": "xt2lmc8v",
+					"name": "runAfter",
+					"type": "date",
+					"required": false,
+					"presentable": false,
+					"unique": false,
+					"options": {
+						"min": "",
+						"max": ""
+					}
+				}
+			],
+			"indexes": [
+				"CREATE INDEX ` + "`" + `idx_jobs_status_runAfter` + "`" + ` ON ` + "`" + `jobs` + "`" + ` (\n  ` + "`" + `status` + "`" + `,\n  ` + "`" + `runAfter` + "`" + `\n)"
+			],
+			"listRule": null,
+			"viewRule": null,
+			"createRule": null,
+			"updateRule": null,
+			"deleteRule": null,
+			"options": {}
+		}`
+
+		collection := &models.Collection{}
+		if err := json.Unmarshal([]byte(jsonData), &collection); err != nil {
+			return err
+		}
+
+		return daos.New(db).SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("b2ojx7w5kzq1m9d")
+		if err != nil {
+			return err
+		}
+
+		return dao.DeleteCollection(collection)
+	})
+}
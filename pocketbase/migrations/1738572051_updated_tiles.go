@@ -0,0 +1,53 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("ewi0x38j6dujau8")
+		if err != nil {
+			return err
+		}
+
+		// add
+		new_progress := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "f6yhu0ia",
+			"name": "progress",
+			"type": "number",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"min": 0,
+				"max": 100,
+				"noDecimal": false
+			}
+		}`), new_progress)
+		collection.Schema.AddField(new_progress)
+
+		return dao.SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("ewi0x38j6dujau8")
+		if err != nil {
+			return err
+		}
+
+		// remove
+		collection.Schema.RemoveField("f6yhu0ia")
+
+		return dao.SaveCollection(collection)
+	})
+}
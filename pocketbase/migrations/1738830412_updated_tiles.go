@@ -0,0 +1,70 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("ewi0x38j6dujau8")
+		if err != nil {
+			return err
+		}
+
+		// add
+		new_center := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "l2dvoy5c",
+			"name": "center",
+			"type": "json",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"maxSize": 2000000
+			}
+		}`), new_center)
+		collection.Schema.AddField(new_center)
+
+		// add
+		new_corners := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "s8gqe4ta",
+			"name": "corners",
+			"type": "json",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"maxSize": 2000000
+			}
+		}`), new_corners)
+		collection.Schema.AddField(new_corners)
+
+		return dao.SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("ewi0x38j6dujau8")
+		if err != nil {
+			return err
+		}
+
+		// remove
+		collection.Schema.RemoveField("l2dvoy5c")
+
+		// remove
+		collection.Schema.RemoveField("s8gqe4ta")
+
+		return dao.SaveCollection(collection)
+	})
+}
@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("ewi0x38j6dujau8")
+		if err != nil {
+			return err
+		}
+
+		// add
+		new_geometry := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "yw5jrn0e",
+			"name": "geometry",
+			"type": "json",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"maxSize": 2000000
+			}
+		}`), new_geometry)
+		collection.Schema.AddField(new_geometry)
+
+		return dao.SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("ewi0x38j6dujau8")
+		if err != nil {
+			return err
+		}
+
+		// remove
+		collection.Schema.RemoveField("yw5jrn0e")
+
+		return dao.SaveCollection(collection)
+	})
+}
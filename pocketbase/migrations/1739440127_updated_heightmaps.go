@@ -0,0 +1,74 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("qh5a6aeji7vbauu")
+		if err != nil {
+			return err
+		}
+
+		// add
+		new_elevation := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "wp7dn3qk",
+			"name": "elevation",
+			"type": "file",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"mimeTypes": [],
+				"thumbs": [],
+				"maxSelect": 1,
+				"maxSize": 104857600,
+				"protected": false
+			}
+		}`), new_elevation)
+		collection.Schema.AddField(new_elevation)
+
+		// add
+		new_elevationInfo := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "c4ah9ufz",
+			"name": "elevationInfo",
+			"type": "json",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"maxSize": 2000000
+			}
+		}`), new_elevationInfo)
+		collection.Schema.AddField(new_elevationInfo)
+
+		return dao.SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("qh5a6aeji7vbauu")
+		if err != nil {
+			return err
+		}
+
+		// remove
+		collection.Schema.RemoveField("wp7dn3qk")
+
+		// remove
+		collection.Schema.RemoveField("c4ah9ufz")
+
+		return dao.SaveCollection(collection)
+	})
+}
@@ -0,0 +1,56 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("qh5a6aeji7vbauu")
+		if err != nil {
+			return err
+		}
+
+		// add
+		new_encoding := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "o1ebg6xr",
+			"name": "encoding",
+			"type": "select",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"maxSelect": 1,
+				"values": [
+					"terrain-rgb",
+					"terrarium",
+					"gray16"
+				]
+			}
+		}`), new_encoding)
+		collection.Schema.AddField(new_encoding)
+
+		return dao.SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("qh5a6aeji7vbauu")
+		if err != nil {
+			return err
+		}
+
+		// remove
+		collection.Schema.RemoveField("o1ebg6xr")
+
+		return dao.SaveCollection(collection)
+	})
+}
@@ -0,0 +1,185 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("qh5a6aeji7vbauu")
+		if err != nil {
+			return err
+		}
+
+		// add
+		new_hillshade := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "o0l30skx",
+			"name": "hillshade",
+			"type": "file",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"mimeTypes": [],
+				"thumbs": [],
+				"maxSelect": 1,
+				"maxSize": 5242880,
+				"protected": false
+			}
+		}`), new_hillshade)
+		collection.Schema.AddField(new_hillshade)
+
+		// add
+		new_slope := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "7wc2at1f",
+			"name": "slope",
+			"type": "file",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"mimeTypes": [],
+				"thumbs": [],
+				"maxSelect": 1,
+				"maxSize": 5242880,
+				"protected": false
+			}
+		}`), new_slope)
+		collection.Schema.AddField(new_slope)
+
+		// add
+		new_aspect := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "cvi5mvsy",
+			"name": "aspect",
+			"type": "file",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"mimeTypes": [],
+				"thumbs": [],
+				"maxSelect": 1,
+				"maxSize": 5242880,
+				"protected": false
+			}
+		}`), new_aspect)
+		collection.Schema.AddField(new_aspect)
+
+		// add
+		new_sunAzimuth := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "w7wz7c8b",
+			"name": "sunAzimuth",
+			"type": "number",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"min": 0,
+				"max": 360,
+				"noDecimal": false
+			}
+		}`), new_sunAzimuth)
+		collection.Schema.AddField(new_sunAzimuth)
+
+		// add
+		new_sunAltitude := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "glfo50rm",
+			"name": "sunAltitude",
+			"type": "number",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"min": 0,
+				"max": 90,
+				"noDecimal": false
+			}
+		}`), new_sunAltitude)
+		collection.Schema.AddField(new_sunAltitude)
+
+		// add
+		new_meanSlope := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "82u45vhi",
+			"name": "meanSlope",
+			"type": "number",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"min": null,
+				"max": null,
+				"noDecimal": false
+			}
+		}`), new_meanSlope)
+		collection.Schema.AddField(new_meanSlope)
+
+		// add
+		new_maxSlope := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "630vl4vp",
+			"name": "maxSlope",
+			"type": "number",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"min": null,
+				"max": null,
+				"noDecimal": false
+			}
+		}`), new_maxSlope)
+		collection.Schema.AddField(new_maxSlope)
+
+		return dao.SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("qh5a6aeji7vbauu")
+		if err != nil {
+			return err
+		}
+
+		// remove
+		collection.Schema.RemoveField("o0l30skx")
+
+		// remove
+		collection.Schema.RemoveField("7wc2at1f")
+
+		// remove
+		collection.Schema.RemoveField("cvi5mvsy")
+
+		// remove
+		collection.Schema.RemoveField("w7wz7c8b")
+
+		// remove
+		collection.Schema.RemoveField("glfo50rm")
+
+		// remove
+		collection.Schema.RemoveField("82u45vhi")
+
+		// remove
+		collection.Schema.RemoveField("630vl4vp")
+
+		return dao.SaveCollection(collection)
+	})
+}
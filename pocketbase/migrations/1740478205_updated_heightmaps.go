@@ -0,0 +1,76 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("qh5a6aeji7vbauu")
+		if err != nil {
+			return err
+		}
+
+		// add
+		new_normalMap := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "1l587y9v",
+			"name": "normalMap",
+			"type": "file",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"mimeTypes": [],
+				"thumbs": [],
+				"maxSelect": 1,
+				"maxSize": 5242880,
+				"protected": false
+			}
+		}`), new_normalMap)
+		collection.Schema.AddField(new_normalMap)
+
+		// add
+		new_normalStrength := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "598z2kbd",
+			"name": "normalStrength",
+			"type": "number",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"min": 0,
+				"max": null,
+				"noDecimal": false
+			}
+		}`), new_normalStrength)
+		collection.Schema.AddField(new_normalStrength)
+
+		return dao.SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("qh5a6aeji7vbauu")
+		if err != nil {
+			return err
+		}
+
+		// remove
+		collection.Schema.RemoveField("1l587y9v")
+
+		// remove
+		collection.Schema.RemoveField("598z2kbd")
+
+		return dao.SaveCollection(collection)
+	})
+}
@@ -0,0 +1,74 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		jsonData := `{
+			"id": "r5kq2vn8xw3ftla",
+			"created": "2025-03-01 07:12:25.402Z",
+			"updated": "2025-03-01 07:12:25.402Z",
+			"name": "palettes",
+			"type": "base",
+			"system": false,
+			"schema": [
+				{
+					"system": false,
+					"id": "q8dn2wtb",
+					"name": "name",
+					"type": "text",
+					"required": true,
+					"presentable": true,
+					"unique": false,
+					"options": {
+						"min": null,
+						"max": null,
+						"pattern": ""
+					}
+				},
+				{
+					"system": false,
+					"id": "fz6kyr1m",
+					"name": "default",
+					"type": "bool",
+					"required": false,
+					"presentable": false,
+					"unique": false,
+					"options": {}
+				}
+			],
+			"indexes": [
+				"CREATE UNIQUE INDEX ` + "`" + `idx_palettes_name` + "`" + ` ON ` + "`" + `palettes` + "`" + ` (` + "`" + `name` + "`" + `)"
+			],
+			"listRule": "",
+			"viewRule": "",
+			"createRule": null,
+			"updateRule": null,
+			"deleteRule": null,
+			"options": {}
+		}`
+
+		collection := &models.Collection{}
+		if err := json.Unmarshal([]byte(jsonData), &collection); err != nil {
+			return err
+		}
+
+		return daos.New(db).SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("r5kq2vn8xw3ftla")
+		if err != nil {
+			return err
+		}
+
+		return dao.DeleteCollection(collection)
+	})
+}
@@ -0,0 +1,136 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		jsonData := `{
+			"id": "h3wz8cj1pm6yd0s",
+			"created": "2025-03-01 07:13:22.118Z",
+			"updated": "2025-03-01 07:13:22.118Z",
+			"name": "landcover_classes",
+			"type": "base",
+			"system": false,
+			"schema": [
+				{
+					"system": false,
+					"id": "mk2v7gxa",
+					"name": "palette",
+					"type": "relation",
+					"required": true,
+					"presentable": false,
+					"unique": false,
+					"options": {
+						"collectionId": "r5kq2vn8xw3ftla",
+						"cascadeDelete": true,
+						"minSelect": null,
+						"maxSelect": 1,
+						"displayFields": null
+					}
+				},
+				{
+					"system": false,
+					"id": "t0bqe5nw",
+					"name": "name",
+					"type": "text",
+					"required": true,
+					"presentable": true,
+					"unique": false,
+					"options": {
+						"min": null,
+						"max": null,
+						"pattern": ""
+					}
+				},
+				{
+					"system": false,
+					"id": "yx4hc9ul",
+					"name": "color",
+					"type": "text",
+					"required": true,
+					"presentable": false,
+					"unique": false,
+					"options": {
+						"min": null,
+						"max": null,
+						"pattern": "^#[0-9a-fA-F]{6}$"
+					}
+				},
+				{
+					"system": false,
+					"id": "p1sgd6ro",
+					"name": "texture",
+					"type": "text",
+					"required": false,
+					"presentable": false,
+					"unique": false,
+					"options": {
+						"min": null,
+						"max": null,
+						"pattern": "^#[0-9a-fA-F]{8}$"
+					}
+				},
+				{
+					"system": false,
+					"id": "cw9jn3fe",
+					"name": "classId",
+					"type": "number",
+					"required": false,
+					"presentable": false,
+					"unique": false,
+					"options": {
+						"min": 0,
+						"max": 255,
+						"noDecimal": true
+					}
+				},
+				{
+					"system": false,
+					"id": "z7ra0kvb",
+					"name": "order",
+					"type": "number",
+					"required": false,
+					"presentable": false,
+					"unique": false,
+					"options": {
+						"min": null,
+						"max": null,
+						"noDecimal": true
+					}
+				}
+			],
+			"indexes": [
+				"CREATE UNIQUE INDEX ` + "`" + `idx_landcover_classes_palette_name` + "`" + ` ON ` + "`" + `landcover_classes` + "`" + ` (\n  ` + "`" + `palette` + "`" + `,\n  ` + "`" + `name` + "`" + `\n)"
+			],
+			"listRule": "",
+			"viewRule": "",
+			"createRule": null,
+			"updateRule": null,
+			"deleteRule": null,
+			"options": {}
+		}`
+
+		collection := &models.Collection{}
+		if err := json.Unmarshal([]byte(jsonData), &collection); err != nil {
+			return err
+		}
+
+		return daos.New(db).SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("h3wz8cj1pm6yd0s")
+		if err != nil {
+			return err
+		}
+
+		return dao.DeleteCollection(collection)
+	})
+}
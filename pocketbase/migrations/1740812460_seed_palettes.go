@@ -0,0 +1,72 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// dynamicWorldClasses are the classes the landcover images were drawn with
+// before palettes were configurable, in Dynamic World's class order.
+var dynamicWorldClasses = []struct {
+	name    string
+	color   string
+	texture string
+}{
+	{"water", "#419bdf", "#ffff00ff"},
+	{"trees", "#397d49", "#00ffffff"},
+	{"grass", "#88b053", "#00ff00ff"},
+	{"flooded_vegetation", "#7a87c6", "#ff0000ff"},
+	{"crops", "#e49635", "#00ffff00"},
+	{"shrub", "#dfc35a", "#ff00ffff"},
+	{"built", "#c4281b", "#ff0000ff"},
+	{"bare", "#a59b8f", "#00ff0000"},
+	{"snow", "#b39fe1", "#ffffffff"},
+}
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		palettes, err := dao.FindCollectionByNameOrId("palettes")
+		if err != nil {
+			return err
+		}
+		classes, err := dao.FindCollectionByNameOrId("landcover_classes")
+		if err != nil {
+			return err
+		}
+
+		palette := models.NewRecord(palettes)
+		palette.Set("name", "dynamic-world")
+		palette.Set("default", true)
+		if err := dao.SaveRecord(palette); err != nil {
+			return err
+		}
+
+		for i, class := range dynamicWorldClasses {
+			record := models.NewRecord(classes)
+			record.Set("palette", palette.Id)
+			record.Set("name", class.name)
+			record.Set("color", class.color)
+			record.Set("texture", class.texture)
+			record.Set("classId", i)
+			record.Set("order", i)
+			if err := dao.SaveRecord(record); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		palette, err := dao.FindFirstRecordByData("palettes", "name", "dynamic-world")
+		if err != nil {
+			return nil
+		}
+
+		return dao.DeleteRecord(palette)
+	})
+}
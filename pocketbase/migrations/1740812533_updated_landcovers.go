@@ -0,0 +1,55 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("7j6bjnt1r56ut84")
+		if err != nil {
+			return err
+		}
+
+		// add
+		new_palette := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "wjbisvu4",
+			"name": "palette",
+			"type": "relation",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"collectionId": "r5kq2vn8xw3ftla",
+				"cascadeDelete": false,
+				"minSelect": null,
+				"maxSelect": 1,
+				"displayFields": null
+			}
+		}`), new_palette)
+		collection.Schema.AddField(new_palette)
+
+		return dao.SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("7j6bjnt1r56ut84")
+		if err != nil {
+			return err
+		}
+
+		// remove
+		collection.Schema.RemoveField("wjbisvu4")
+
+		return dao.SaveCollection(collection)
+	})
+}
@@ -0,0 +1,57 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("r5kq2vn8xw3ftla")
+		if err != nil {
+			return err
+		}
+
+		// add
+		new_distance := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "rttd9ckh",
+			"name": "distance",
+			"type": "select",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"maxSelect": 1,
+				"values": [
+					"ciede2000",
+					"cielab",
+					"rgb",
+					"hsl"
+				]
+			}
+		}`), new_distance)
+		collection.Schema.AddField(new_distance)
+
+		return dao.SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("r5kq2vn8xw3ftla")
+		if err != nil {
+			return err
+		}
+
+		// remove
+		collection.Schema.RemoveField("rttd9ckh")
+
+		return dao.SaveCollection(collection)
+	})
+}
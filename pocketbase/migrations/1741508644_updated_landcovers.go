@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("7j6bjnt1r56ut84")
+		if err != nil {
+			return err
+		}
+
+		// add
+		new_metrics := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "rx6tparm",
+			"name": "metrics",
+			"type": "json",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"maxSize": 2000000
+			}
+		}`), new_metrics)
+		collection.Schema.AddField(new_metrics)
+
+		return dao.SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("7j6bjnt1r56ut84")
+		if err != nil {
+			return err
+		}
+
+		// remove
+		collection.Schema.RemoveField("rx6tparm")
+
+		return dao.SaveCollection(collection)
+	})
+}
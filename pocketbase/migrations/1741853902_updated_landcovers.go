@@ -0,0 +1,95 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("7j6bjnt1r56ut84")
+		if err != nil {
+			return err
+		}
+
+		// add
+		new_majorityRadius := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "rf7qm7f7",
+			"name": "majorityRadius",
+			"type": "number",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"min": 0,
+				"max": 10,
+				"noDecimal": true
+			}
+		}`), new_majorityRadius)
+		collection.Schema.AddField(new_majorityRadius)
+
+		// add
+		new_minMappingUnit := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "rm101a2e",
+			"name": "minMappingUnit",
+			"type": "number",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"min": 0,
+				"max": null,
+				"noDecimal": false
+			}
+		}`), new_minMappingUnit)
+		collection.Schema.AddField(new_minMappingUnit)
+
+		// add
+		new_maxHoleArea := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "y45gctp4",
+			"name": "maxHoleArea",
+			"type": "number",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"min": 0,
+				"max": null,
+				"noDecimal": false
+			}
+		}`), new_maxHoleArea)
+		collection.Schema.AddField(new_maxHoleArea)
+
+		return dao.SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("7j6bjnt1r56ut84")
+		if err != nil {
+			return err
+		}
+
+		// remove
+		collection.Schema.RemoveField("rf7qm7f7")
+
+		// remove
+		collection.Schema.RemoveField("rm101a2e")
+
+		// remove
+		collection.Schema.RemoveField("y45gctp4")
+
+		return dao.SaveCollection(collection)
+	})
+}
@@ -0,0 +1,74 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("h3wz8cj1pm6yd0s")
+		if err != nil {
+			return err
+		}
+
+		// add
+		new_priority := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "av7p15lc",
+			"name": "priority",
+			"type": "number",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"min": 0,
+				"max": null,
+				"noDecimal": true
+			}
+		}`), new_priority)
+		collection.Schema.AddField(new_priority)
+
+		// add
+		new_minCoverage := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "7jg8f60u",
+			"name": "minCoverage",
+			"type": "number",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"min": 0,
+				"max": 1,
+				"noDecimal": false
+			}
+		}`), new_minCoverage)
+		collection.Schema.AddField(new_minCoverage)
+
+		return dao.SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("h3wz8cj1pm6yd0s")
+		if err != nil {
+			return err
+		}
+
+		// remove
+		collection.Schema.RemoveField("av7p15lc")
+
+		// remove
+		collection.Schema.RemoveField("7jg8f60u")
+
+		return dao.SaveCollection(collection)
+	})
+}
@@ -0,0 +1,55 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("7j6bjnt1r56ut84")
+		if err != nil {
+			return err
+		}
+
+		// add
+		new_coverage_100 := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "th5mrnum",
+			"name": "coverage_100",
+			"type": "file",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"mimeTypes": [],
+				"thumbs": [],
+				"maxSelect": 1,
+				"maxSize": 20971520,
+				"protected": false
+			}
+		}`), new_coverage_100)
+		collection.Schema.AddField(new_coverage_100)
+
+		return dao.SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("7j6bjnt1r56ut84")
+		if err != nil {
+			return err
+		}
+
+		// remove
+		collection.Schema.RemoveField("th5mrnum")
+
+		return dao.SaveCollection(collection)
+	})
+}
@@ -0,0 +1,162 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("ewi0x38j6dujau8")
+		if err != nil {
+			return err
+		}
+
+		// add
+		new_gridSize := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "9j0o0ovd",
+			"name": "gridSize",
+			"type": "number",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"min": 2,
+				"max": 2048,
+				"noDecimal": true
+			}
+		}`), new_gridSize)
+		collection.Schema.AddField(new_gridSize)
+
+		// add
+		new_gridCellSize := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "pbko932w",
+			"name": "gridCellSize",
+			"type": "number",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"min": 0,
+				"max": null,
+				"noDecimal": false
+			}
+		}`), new_gridCellSize)
+		collection.Schema.AddField(new_gridCellSize)
+
+		// add
+		new_grid := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "qut9kanu",
+			"name": "grid",
+			"type": "json",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"maxSize": 2000000
+			}
+		}`), new_grid)
+		collection.Schema.AddField(new_grid)
+
+		// add
+		new_grid_color := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "ivo2nfwe",
+			"name": "grid_color",
+			"type": "file",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"mimeTypes": [],
+				"thumbs": [],
+				"maxSelect": 1,
+				"maxSize": 5242880,
+				"protected": false
+			}
+		}`), new_grid_color)
+		collection.Schema.AddField(new_grid_color)
+
+		// add
+		new_grid_texture := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "7v0n90ia",
+			"name": "grid_texture",
+			"type": "file",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"mimeTypes": [],
+				"thumbs": [],
+				"maxSelect": 1,
+				"maxSize": 5242880,
+				"protected": false
+			}
+		}`), new_grid_texture)
+		collection.Schema.AddField(new_grid_texture)
+
+		// add
+		new_grid_coverage := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "kkcs0be2",
+			"name": "grid_coverage",
+			"type": "file",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"mimeTypes": [],
+				"thumbs": [],
+				"maxSelect": 1,
+				"maxSize": 104857600,
+				"protected": false
+			}
+		}`), new_grid_coverage)
+		collection.Schema.AddField(new_grid_coverage)
+
+		return dao.SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("ewi0x38j6dujau8")
+		if err != nil {
+			return err
+		}
+
+		// remove
+		collection.Schema.RemoveField("9j0o0ovd")
+
+		// remove
+		collection.Schema.RemoveField("pbko932w")
+
+		// remove
+		collection.Schema.RemoveField("qut9kanu")
+
+		// remove
+		collection.Schema.RemoveField("ivo2nfwe")
+
+		// remove
+		collection.Schema.RemoveField("7v0n90ia")
+
+		// remove
+		collection.Schema.RemoveField("kkcs0be2")
+
+		return dao.SaveCollection(collection)
+	})
+}
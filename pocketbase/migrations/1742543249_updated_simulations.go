@@ -0,0 +1,162 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("0hdk5ehquq5qjpg")
+		if err != nil {
+			return err
+		}
+
+		// add
+		new_gridSize := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "ruz631br",
+			"name": "gridSize",
+			"type": "number",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"min": 2,
+				"max": 2048,
+				"noDecimal": true
+			}
+		}`), new_gridSize)
+		collection.Schema.AddField(new_gridSize)
+
+		// add
+		new_gridCellSize := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "0mmcr8o3",
+			"name": "gridCellSize",
+			"type": "number",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"min": 0,
+				"max": null,
+				"noDecimal": false
+			}
+		}`), new_gridCellSize)
+		collection.Schema.AddField(new_gridCellSize)
+
+		// add
+		new_grid := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "7ah4lhmc",
+			"name": "grid",
+			"type": "json",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"maxSize": 2000000
+			}
+		}`), new_grid)
+		collection.Schema.AddField(new_grid)
+
+		// add
+		new_grid_color := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "la80n9y5",
+			"name": "grid_color",
+			"type": "file",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"mimeTypes": [],
+				"thumbs": [],
+				"maxSelect": 1,
+				"maxSize": 5242880,
+				"protected": false
+			}
+		}`), new_grid_color)
+		collection.Schema.AddField(new_grid_color)
+
+		// add
+		new_grid_texture := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "9g8arcl7",
+			"name": "grid_texture",
+			"type": "file",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"mimeTypes": [],
+				"thumbs": [],
+				"maxSelect": 1,
+				"maxSize": 5242880,
+				"protected": false
+			}
+		}`), new_grid_texture)
+		collection.Schema.AddField(new_grid_texture)
+
+		// add
+		new_grid_coverage := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "s9iypncy",
+			"name": "grid_coverage",
+			"type": "file",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"mimeTypes": [],
+				"thumbs": [],
+				"maxSelect": 1,
+				"maxSize": 104857600,
+				"protected": false
+			}
+		}`), new_grid_coverage)
+		collection.Schema.AddField(new_grid_coverage)
+
+		return dao.SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("0hdk5ehquq5qjpg")
+		if err != nil {
+			return err
+		}
+
+		// remove
+		collection.Schema.RemoveField("ruz631br")
+
+		// remove
+		collection.Schema.RemoveField("0mmcr8o3")
+
+		// remove
+		collection.Schema.RemoveField("7ah4lhmc")
+
+		// remove
+		collection.Schema.RemoveField("la80n9y5")
+
+		// remove
+		collection.Schema.RemoveField("9g8arcl7")
+
+		// remove
+		collection.Schema.RemoveField("s9iypncy")
+
+		return dao.SaveCollection(collection)
+	})
+}
@@ -0,0 +1,76 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("7j6bjnt1r56ut84")
+		if err != nil {
+			return err
+		}
+
+		// add
+		new_classifier := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "7bdt6t3t",
+			"name": "classifier",
+			"type": "text",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"min": null,
+				"max": null,
+				"pattern": ""
+			}
+		}`), new_classifier)
+		collection.Schema.AddField(new_classifier)
+
+		// add
+		new_sourceTile := &schema.SchemaField{}
+		json.Unmarshal([]byte(`{
+			"system": false,
+			"id": "f99z1o2a",
+			"name": "sourceTile",
+			"type": "relation",
+			"required": false,
+			"presentable": false,
+			"unique": false,
+			"options": {
+				"collectionId": "ewi0x38j6dujau8",
+				"cascadeDelete": false,
+				"minSelect": null,
+				"maxSelect": 1,
+				"displayFields": null
+			}
+		}`), new_sourceTile)
+		collection.Schema.AddField(new_sourceTile)
+
+		return dao.SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db);
+
+		collection, err := dao.FindCollectionByNameOrId("7j6bjnt1r56ut84")
+		if err != nil {
+			return err
+		}
+
+		// remove
+		collection.Schema.RemoveField("7bdt6t3t")
+
+		// remove
+		collection.Schema.RemoveField("f99z1o2a")
+
+		return dao.SaveCollection(collection)
+	})
+}
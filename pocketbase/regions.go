@@ -0,0 +1,112 @@
+package main
+
+import (
+	"app/lib/jobs"
+	"app/lib/mapbox"
+	"app/lib/mosaic"
+	"app/lib/tilemath"
+	utils "app/lib/utils"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+type regionRequest struct {
+	// Geometry is a GeoJSON Polygon or a Feature/FeatureCollection holding one.
+	Geometry json.RawMessage `json:"geometry"`
+	// BBox is used instead of Geometry when no geometry is given.
+	BBox []float64 `json:"bbox"`
+	// Zoom or MetersPerPixel sets the resolution of the region.
+	Zoom           *int    `json:"zoom"`
+	MetersPerPixel float64 `json:"metersPerPixel"`
+	Provider       string  `json:"provider"`
+}
+
+// onRegionCreate handles POST /api/terrain/regions. It stores a tile record
+// covering the requested polygon or bbox and queues it; the imagery and
+// elevation of every covering tile are stitched and cropped by the tile job.
+func onRegionCreate(c echo.Context, app *pocketbase.PocketBase, queue *jobs.Queue) error {
+	body := regionRequest{}
+	if err := c.Bind(&body); err != nil {
+		return apis.NewBadRequestError("Failed to read the request body.", err)
+	}
+
+	var polygon mosaic.Polygon
+	switch {
+	case len(body.Geometry) > 0:
+		parsed, err := mosaic.ParsePolygon(body.Geometry)
+		if err != nil {
+			return apis.NewBadRequestError("Invalid geometry.", err)
+		}
+		polygon = parsed
+	case len(body.BBox) == 4 && body.BBox[0] < body.BBox[2] && body.BBox[1] < body.BBox[3]:
+		polygon = mosaic.PolygonFromBBox(tilemath.BBox(body.BBox))
+	default:
+		return apis.NewBadRequestError("Either a geometry or a [west, south, east, north] bbox is required.", nil)
+	}
+
+	provider, err := mapbox.ProviderForName(body.Provider)
+	if err != nil {
+		return apis.NewBadRequestError("Invalid provider.", err)
+	}
+
+	var zoom int
+	switch {
+	case body.Zoom != nil:
+		zoom = *body.Zoom
+	case body.MetersPerPixel > 0:
+		_, lat := polygon.BBox().Center()
+		zoom = mapbox.ZoomForMetersPerPixel(body.MetersPerPixel, lat, provider.MaxZoom(), provider.TileSize())
+	default:
+		return apis.NewBadRequestError("Either zoom or metersPerPixel is required.", nil)
+	}
+	if zoom < 0 || zoom > provider.MaxZoom() {
+		return apis.NewBadRequestError(fmt.Sprintf("Zoom must be between 0 and %d.", provider.MaxZoom()), nil)
+	}
+
+	region := mosaic.Region{Polygon: polygon, Zoom: zoom, TileSize: provider.TileSize()}
+	tiles := region.Tiles()
+	if len(tiles) == 0 {
+		return apis.NewBadRequestError("The region covers no tiles.", nil)
+	}
+	maxTiles := utils.IntFromEnv("REGION_MAX_TILES", 64)
+	if len(tiles) > maxTiles {
+		return apis.NewBadRequestError(fmt.Sprintf("The region covers %d tiles at zoom %d, the limit is %d.", len(tiles), zoom, maxTiles), nil)
+	}
+
+	collection, err := app.Dao().FindCollectionByNameOrId("tiles")
+	if err != nil {
+		return err
+	}
+
+	bbox := region.BBox()
+	lon, lat := bbox.Center()
+	record := models.NewRecord(collection)
+	record.Set("x", tiles[0].X)
+	record.Set("y", tiles[0].Y)
+	record.Set("zoom", zoom)
+	record.Set("bbox", bbox)
+	record.Set("center", []float64{lon, lat})
+	record.Set("corners", bbox.Corners())
+	record.Set("geometry", map[string]any{
+		"type":        "Polygon",
+		"coordinates": polygon,
+	})
+	record.Set("provider", provider.Name())
+	record.Set("status", "pending")
+	record.Set("progress", 0)
+	if err := app.Dao().SaveRecord(record); err != nil {
+		return err
+	}
+
+	if _, err := queue.Enqueue("tile.create", record.Id); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, record)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"app/lib/palettes"
+	"app/lib/splat"
+	utils "app/lib/utils"
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"net/http"
+	"strconv"
+
+	"github.com/disintegration/imaging"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+)
+
+type splatChannel struct {
+	Image   string `json:"image"`
+	Channel string `json:"channel"`
+	Name    string `json:"name"`
+	ClassId int    `json:"classId"`
+	Color   string `json:"color"`
+}
+
+type splatManifest struct {
+	Width    int            `json:"width"`
+	Height   int            `json:"height"`
+	Blur     float64        `json:"blur"`
+	Images   []string       `json:"images"`
+	Channels []splatChannel `json:"channels"`
+}
+
+// onSplatmapExport handles GET /api/terrain/landcovers/:id/splatmaps. It
+// returns a zip of RGBA splat maps holding the weight of four classes each,
+// plus a manifest.json naming the class in every channel. blur blends class
+// edges with a Gaussian of that many pixels, size resamples the landcover
+// first and format=json returns only the manifest. The channels of a pixel
+// sum to 255, except for unclassified pixels out of the blur's reach of any
+// class, which are 0 everywhere.
+func onSplatmapExport(c echo.Context, app *pocketbase.PocketBase, landcoverPalettes *palettes.Store) error {
+	landcover, err := app.Dao().FindRecordById("landcovers", c.PathParam("id"))
+	if err != nil {
+		return apis.NewNotFoundError("", err)
+	}
+	if landcover.GetString("color") == "" {
+		return apis.NewBadRequestError("The landcover has not been processed yet.", nil)
+	}
+
+	blur := 0.0
+	if blurParam := c.QueryParam("blur"); blurParam != "" {
+		blur, err = strconv.ParseFloat(blurParam, 64)
+		if err != nil || blur < 0 || blur > 50 {
+			return apis.NewBadRequestError("blur must be between 0 and 50 pixels.", nil)
+		}
+	}
+
+	img, err := imaging.Open(utils.GetPathForFileField(landcover, landcover.Collection(), app.DataDir(), "color"))
+	if err != nil {
+		return err
+	}
+	if sizeParam := c.QueryParam("size"); sizeParam != "" {
+		size, err := strconv.Atoi(sizeParam)
+		if err != nil || size < 2 || size > 4097 {
+			return apis.NewBadRequestError("size must be between 2 and 4097.", nil)
+		}
+		// nearest neighbor keeps the classes crisp, blur is what blends them
+		img = imaging.Resize(img, size, size, imaging.NearestNeighbor)
+	}
+
+	palette := landcoverPalettes.ForRecord(landcover)
+	classMap := classMapForImage(img, palette)
+
+	manifest := splatManifest{Width: classMap.Width, Height: classMap.Height, Blur: blur}
+	for i := 0; i*splat.ChannelsPerImage < len(palette.Classes); i++ {
+		manifest.Images = append(manifest.Images, fmt.Sprintf("splatmap_%d.png", i))
+	}
+	for i, class := range palette.Classes {
+		manifest.Channels = append(manifest.Channels, splatChannel{
+			Image:   manifest.Images[i/splat.ChannelsPerImage],
+			Channel: splat.ChannelNames[i%splat.ChannelsPerImage],
+			Name:    class.Name,
+			ClassId: class.ClassId,
+			Color:   fmt.Sprintf("#%02x%02x%02x", class.Color.R, class.Color.G, class.Color.B),
+		})
+	}
+
+	if c.QueryParam("format") == "json" {
+		return c.JSON(http.StatusOK, manifest)
+	}
+
+	header := c.Response().Header()
+	header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="splatmaps_%s.zip"`, landcover.Id))
+	header.Set(echo.HeaderContentType, "application/zip")
+	c.Response().WriteHeader(http.StatusOK)
+
+	images := splat.Images(classMap.Indices, classMap.Width, classMap.Height, len(palette.Classes), blur)
+	archive := zip.NewWriter(c.Response())
+	for i, image := range images {
+		file, err := archive.Create(manifest.Images[i])
+		if err != nil {
+			return err
+		}
+		if err := png.Encode(file, image); err != nil {
+			return err
+		}
+	}
+	file, err := archive.Create("manifest.json")
+	if err != nil {
+		return err
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(manifestJSON); err != nil {
+		return err
+	}
+	return archive.Close()
+}
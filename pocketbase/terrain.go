@@ -0,0 +1,351 @@
+package main
+
+import (
+	"app/lib/elevation"
+	"app/lib/mesh"
+	"app/lib/tilemath"
+	utils "app/lib/utils"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// loadElevation reads the float32 elevation raster of a heightmap record.
+func loadElevation(app *pocketbase.PocketBase, heightmap *models.Record) (*elevation.Grid, error) {
+	if heightmap.GetString("elevation") == "" {
+		return nil, errors.New("heightmap has no elevation raster yet")
+	}
+
+	info := elevation.Info{}
+	if err := heightmap.UnmarshalJSONField("elevationInfo", &info); err != nil {
+		return nil, err
+	}
+
+	filePath := utils.GetPathForFileField(heightmap, heightmap.Collection(), app.DataDir(), "elevation")
+	return elevation.ReadGrid(filePath, info)
+}
+
+// pixelForLonLat returns the fractional pixel of a raster covering bbox in
+// Web Mercator that contains the point.
+func pixelForLonLat(bbox tilemath.BBox, width int, height int, lon float64, lat float64) (float64, float64) {
+	west, east := tilemath.LonToTile(bbox.West(), 0), tilemath.LonToTile(bbox.East(), 0)
+	north, south := tilemath.LatToTile(bbox.North(), 0), tilemath.LatToTile(bbox.South(), 0)
+
+	fx := (tilemath.LonToTile(lon, 0) - west) / (east - west) * float64(width)
+	fy := (tilemath.LatToTile(lat, 0) - north) / (south - north) * float64(height)
+	return fx, fy
+}
+
+// onElevationRequest handles GET /api/terrain/elevation?lon=&lat= and returns
+// the elevation in meters from the most detailed tile covering the point.
+func onElevationRequest(c echo.Context, app *pocketbase.PocketBase) error {
+	lon, lonErr := strconv.ParseFloat(c.QueryParam("lon"), 64)
+	lat, latErr := strconv.ParseFloat(c.QueryParam("lat"), 64)
+	if lonErr != nil || latErr != nil || lon < -180 || lon > 180 || lat < -90 || lat > 90 {
+		return apis.NewBadRequestError("Valid lon and lat query parameters are required.", nil)
+	}
+
+	tile := &models.Record{}
+	err := app.Dao().RecordQuery("tiles").
+		AndWhere(dbx.NewExp(
+			"json_extract([[bbox]], '$[0]') <= {:lon} AND json_extract([[bbox]], '$[2]') >= {:lon} AND "+
+				"json_extract([[bbox]], '$[1]') <= {:lat} AND json_extract([[bbox]], '$[3]') >= {:lat} AND [[heightmap]] != ''",
+			dbx.Params{"lon": lon, "lat": lat},
+		)).
+		OrderBy("zoom DESC").
+		Limit(1).
+		One(tile)
+	if err != nil {
+		return apis.NewNotFoundError("No tile with a heightmap covers this point.", err)
+	}
+
+	heightmap, err := app.Dao().FindRecordById("heightmaps", tile.GetString("heightmap"))
+	if err != nil {
+		return apis.NewNotFoundError("The heightmap of the tile is missing.", err)
+	}
+	grid, err := loadElevation(app, heightmap)
+	if err != nil {
+		return apis.NewNotFoundError("The tile's heightmap has no elevation data.", err)
+	}
+
+	coords, err := utils.CoordsFromBboxString(tile.GetString("bbox"))
+	if err != nil {
+		return err
+	}
+	fx, fy := pixelForLonLat(tilemath.BBox(coords), grid.Width, grid.Height, lon, lat)
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"lon":       lon,
+		"lat":       lat,
+		"elevation": grid.Sample(fx, fy),
+		"tile":      tile.Id,
+		"heightmap": heightmap.Id,
+	})
+}
+
+// onHeightmapExport handles GET /api/terrain/heightmaps/:id/export. It
+// returns the elevation resampled to a 2^n+1 size as a 16-bit grayscale PNG
+// (format=png) or Unity .r16 RAW (format=r16). The meters black and white map
+// to are sent in the X-Height-Min and X-Height-Max headers; format=json
+// returns only those values.
+func onHeightmapExport(c echo.Context, app *pocketbase.PocketBase) error {
+	heightmap, err := app.Dao().FindRecordById("heightmaps", c.PathParam("id"))
+	if err != nil {
+		return apis.NewNotFoundError("", err)
+	}
+
+	grid, err := loadElevation(app, heightmap)
+	if err != nil {
+		return apis.NewBadRequestError("The heightmap has no elevation data.", err)
+	}
+
+	size := elevation.EngineSize(max(grid.Width, grid.Height))
+	if sizeParam := c.QueryParam("size"); sizeParam != "" {
+		size, err = strconv.Atoi(sizeParam)
+		if err != nil || !elevation.IsEngineSize(size) {
+			return apis.NewBadRequestError("size must be 2^n+1 between 33 and 4097, e.g. 513, 1025 or 2049.", nil)
+		}
+	}
+
+	resampled := grid.Resample(size, size)
+	minHeight, maxHeight := resampled.MinMax()
+
+	metadata := map[string]any{
+		"size":      size,
+		"minHeight": minHeight,
+		"maxHeight": maxHeight,
+		"height":    maxHeight - minHeight,
+	}
+
+	header := c.Response().Header()
+	header.Set("X-Height-Min", strconv.FormatFloat(minHeight, 'f', -1, 64))
+	header.Set("X-Height-Max", strconv.FormatFloat(maxHeight, 'f', -1, 64))
+	header.Set("Access-Control-Expose-Headers", "X-Height-Min, X-Height-Max, X-Terrain-Width, X-Terrain-Length")
+
+	// the ground size of the terrain is only known through the tile
+	tile, err := app.Dao().FindFirstRecordByFilter("tiles", "heightmap = {:id}", dbx.Params{"id": heightmap.Id})
+	if err == nil && tile.GetFloat("metersPerPixel") > 0 {
+		width := tile.GetFloat("metersPerPixel") * float64(grid.Width)
+		length := tile.GetFloat("metersPerPixel") * float64(grid.Height)
+		metadata["width"] = width
+		metadata["length"] = length
+		header.Set("X-Terrain-Width", strconv.FormatFloat(width, 'f', -1, 64))
+		header.Set("X-Terrain-Length", strconv.FormatFloat(length, 'f', -1, 64))
+	}
+
+	switch c.QueryParamDefault("format", "png") {
+	case "json":
+		return c.JSON(http.StatusOK, metadata)
+	case "png":
+		header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="heightmap_%d.png"`, size))
+		c.Response().Header().Set(echo.HeaderContentType, "image/png")
+		c.Response().WriteHeader(http.StatusOK)
+		return png.Encode(c.Response(), resampled.Gray16(minHeight, maxHeight))
+	case "r16", "raw":
+		header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="heightmap_%d.r16"`, size))
+		c.Response().Header().Set(echo.HeaderContentType, "application/octet-stream")
+		c.Response().WriteHeader(http.StatusOK)
+		return resampled.WriteR16(c.Response(), minHeight, maxHeight, c.QueryParam("northFirst") == "true")
+	}
+
+	return apis.NewBadRequestError("format must be png, r16 or json.", nil)
+}
+
+// meshTexture returns the PNG draped over a tile's mesh: the satellite image
+// (texture=satellite), the landcover colors (texture=landcover) or nothing
+// (texture=none).
+func meshTexture(app *pocketbase.PocketBase, tile *models.Record, texture string) ([]byte, error) {
+	var filePath string
+	switch texture {
+	case "none":
+		return nil, nil
+	case "satellite":
+		if tile.GetString("satellite") == "" {
+			return nil, errors.New("the tile has no satellite image")
+		}
+		filePath = utils.GetPathForFileField(tile, tile.Collection(), app.DataDir(), "satellite")
+	case "landcover":
+		landcover, err := app.Dao().FindRecordById("landcovers", tile.GetString("landcover"))
+		if err != nil || landcover.GetString("color") == "" {
+			return nil, errors.New("the tile has no landcover colors")
+		}
+		filePath = utils.GetPathForFileField(landcover, landcover.Collection(), app.DataDir(), "color")
+	default:
+		return nil, fmt.Errorf("texture must be satellite, landcover or none")
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return pngTexture(data)
+}
+
+// pngTexture re-encodes an image as PNG unless it already is one. GLB files
+// declare their texture as image/png and the OBJ zip names it .png.
+func pngTexture(data []byte) ([]byte, error) {
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if format == "png" {
+		return data, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, img); err != nil {
+		return nil, err
+	}
+	return encoded.Bytes(), nil
+}
+
+// tileMeshGrid loads the elevation of a tile and returns it together with the
+// ground width and length it covers in meters.
+func tileMeshGrid(app *pocketbase.PocketBase, tile *models.Record) (*elevation.Grid, float64, float64, error) {
+	metersPerPixel := tile.GetFloat("metersPerPixel")
+	if metersPerPixel <= 0 {
+		return nil, 0, 0, errors.New("the tile has no metersPerPixel")
+	}
+
+	heightmap, err := app.Dao().FindRecordById("heightmaps", tile.GetString("heightmap"))
+	if err != nil {
+		return nil, 0, 0, errors.New("the tile has no heightmap")
+	}
+	grid, err := loadElevation(app, heightmap)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	return grid, metersPerPixel * float64(grid.Width), metersPerPixel * float64(grid.Height), nil
+}
+
+// onTileMesh handles GET /api/terrain/tiles/:id/mesh. It triangulates the
+// tile's elevation on a grid of resolution vertices along the longer side
+// and returns binary glTF (format=gltf) or a zip of OBJ, MTL and texture
+// (format=obj). Positions are in meters, centered on the tile. With
+// maxError the grid is simplified to an RTIN whose heights stay within
+// maxError meters of the elevation.
+func onTileMesh(c echo.Context, app *pocketbase.PocketBase) error {
+	tile, err := app.Dao().FindRecordById("tiles", c.PathParam("id"))
+	if err != nil {
+		return apis.NewNotFoundError("", err)
+	}
+
+	format := c.QueryParamDefault("format", "gltf")
+	if format != "gltf" && format != "glb" && format != "obj" {
+		return apis.NewBadRequestError("format must be gltf or obj.", nil)
+	}
+
+	resolution := 256
+	if resolutionParam := c.QueryParam("resolution"); resolutionParam != "" {
+		resolution, err = strconv.Atoi(resolutionParam)
+		if err != nil || resolution < 2 || resolution > 2048 {
+			return apis.NewBadRequestError("resolution must be between 2 and 2048.", nil)
+		}
+	}
+
+	grid, width, length, err := tileMeshGrid(app, tile)
+	if err != nil {
+		return apis.NewBadRequestError(fmt.Sprintf("Can't build a mesh: %s.", err), err)
+	}
+
+	texture, err := meshTexture(app, tile, c.QueryParamDefault("texture", "satellite"))
+	if err != nil {
+		return apis.NewBadRequestError(fmt.Sprintf("Can't texture the mesh: %s.", err), err)
+	}
+
+	var terrain *mesh.Mesh
+	if maxErrorParam := c.QueryParam("maxError"); maxErrorParam != "" {
+		maxError, err := strconv.ParseFloat(maxErrorParam, 64)
+		if err != nil || maxError < 0 {
+			return apis.NewBadRequestError("maxError must be a non-negative number of meters.", nil)
+		}
+
+		// the RTIN needs a square 2^n+1 grid, the UVs still cover the whole
+		// texture so non-square regions only stretch the triangles
+		size := mesh.RTINSize(resolution)
+		rtin, err := mesh.NewRTIN(grid.Resample(size, size))
+		if err != nil {
+			return err
+		}
+		terrain = rtin.Mesh(maxError, width, length)
+	} else {
+		// keep the vertex spacing square on non-square regions
+		columns, rows := resolution, resolution
+		if grid.Width > grid.Height {
+			rows = max(2, int(math.Round(float64(resolution)*float64(grid.Height)/float64(grid.Width))))
+		} else if grid.Height > grid.Width {
+			columns = max(2, int(math.Round(float64(resolution)*float64(grid.Width)/float64(grid.Height))))
+		}
+		terrain = mesh.FromGrid(grid.Resample(columns, rows), width, length)
+	}
+
+	header := c.Response().Header()
+	header.Set("X-Mesh-Vertices", strconv.Itoa(terrain.VertexCount()))
+	header.Set("X-Mesh-Triangles", strconv.Itoa(len(terrain.Indices)/3))
+	header.Set("Access-Control-Expose-Headers", "X-Mesh-Vertices, X-Mesh-Triangles")
+
+	return writeMesh(c, terrain, texture, format, fmt.Sprintf("tile_%s", tile.Id))
+}
+
+// writeMesh sends a mesh as GLB, or as a zip of OBJ, MTL and texture for
+// format=obj.
+func writeMesh(c echo.Context, terrain *mesh.Mesh, texture []byte, format string, name string) error {
+	header := c.Response().Header()
+	if format == "obj" {
+		header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, name))
+		header.Set(echo.HeaderContentType, "application/zip")
+		c.Response().WriteHeader(http.StatusOK)
+
+		archive := zip.NewWriter(c.Response())
+		textureFile := ""
+		if len(texture) > 0 {
+			textureFile = name + ".png"
+			file, err := archive.Create(textureFile)
+			if err != nil {
+				return err
+			}
+			if _, err := file.Write(texture); err != nil {
+				return err
+			}
+		}
+		file, err := archive.Create(name + ".mtl")
+		if err != nil {
+			return err
+		}
+		if err := mesh.WriteMTL(file, textureFile); err != nil {
+			return err
+		}
+		file, err = archive.Create(name + ".obj")
+		if err != nil {
+			return err
+		}
+		if err := terrain.WriteOBJ(file, name+".mtl"); err != nil {
+			return err
+		}
+		return archive.Close()
+	}
+
+	header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.glb"`, name))
+	header.Set(echo.HeaderContentType, "model/gltf-binary")
+	c.Response().WriteHeader(http.StatusOK)
+	return terrain.WriteGLB(c.Response(), texture)
+}
@@ -114,6 +114,9 @@ const mapTile = (tile) => {
     id: tile.id,
     bbox: tile.bbox,
     center,
+    status: tile.status,
+    progress: tile.progress,
+    error: tile.error,
     landcover: mapLandcover(landcover),
     oceanData: mapOceanData(oceanData),
     heightmap: heightmap